@@ -3,6 +3,7 @@ package communication
 import (
 	"fmt"
 	"io"
+	"net"
 	"syscall"
 	"time"
 	"unsafe"
@@ -78,17 +79,24 @@ func Start(serverContext *Server) {
 			if errAccept != nil {
 				fmt.Printf("Accept error: %s\n", errAccept.Error())
 			} else {
-				// Get IPv4 address
-				address := newAddress.(*syscall.SockaddrInet4)
-				ipv4 := fmt.Sprintf("%d.%d.%d.%d", address.Addr[0], address.Addr[1], address.Addr[2], address.Addr[3])
-				port := address.Port
+				// Get client address, socket passed by service manager does not have to be IPv4
+				ip := "unknown"
+				port := 0
+				switch address := newAddress.(type) {
+				case *syscall.SockaddrInet4:
+					ip = fmt.Sprintf("%d.%d.%d.%d", address.Addr[0], address.Addr[1], address.Addr[2], address.Addr[3])
+					port = address.Port
+				case *syscall.SockaddrInet6:
+					ip = net.IP(address.Addr[:]).String()
+					port = address.Port
+				}
 
 				reader, writer := io.Pipe()
 
 				newClient := &Client{
 					UID:               serverContext.NextClientID,
 					Socket:            newSocketDescriptor,
-					ip:                ipv4,
+					ip:                ip,
 					port:              port,
 					address:           newAddress,
 					LastCommunication: time.Now().Unix(),
@@ -26,6 +26,9 @@ const (
 	errorThreshold = 0.66666
 )
 
+// Wait limits of values which differ from limitString, by message type and key
+var valueLimits = map[int]map[string]int{}
+
 type Message struct {
 	// Message ID
 	Id int
@@ -63,6 +66,43 @@ func isControl(character byte) bool {
 	return false
 }
 
+// Sets wait limit of values of given key in messages of given type, must be called before clients connect
+func SetValueLimit(msgType int, key string, limit int) {
+	limits, present := valueLimits[msgType]
+
+	if !present {
+		limits = make(map[string]int)
+		valueLimits[msgType] = limits
+	}
+
+	limits[key] = limit
+}
+
+// Returns wait limit of values of given key in messages of given type
+func valueLimit(msgType int, key string) int {
+	limit, present := valueLimits[msgType][key]
+
+	if !present {
+		return limitString
+	}
+
+	return limit
+}
+
+// Escapes control bytes of value so it can be sent as message value
+func Escape(value string) string {
+	buffer := make([]byte, 0, len(value))
+
+	for i := 0; i < len(value); i++ {
+		if isControl(value[i]) {
+			buffer = append(buffer, escapeCharacter)
+		}
+		buffer = append(buffer, value[i])
+	}
+
+	return string(buffer)
+}
+
 func Decoder(serverContext *Server, client *Client) {
 	buffer := bufio.NewReader(client.Reader)
 
@@ -185,7 +225,7 @@ func Decode(buffer *bufio.Reader) (*Message,error) {
 			return nil,errReadHeader
 		}
 
-		valueData, errReadValue := ReadPairValueString(buffer)
+		valueData, errReadValue := ReadPairValueStringLimit(buffer, valueLimit(msg.Msg, string(headerData)))
 
 		if errReadValue != nil {
 			return nil,errReadValue
@@ -387,9 +427,13 @@ func ReadPairValueInt(reader io.Reader) (int, error) {
 
 // Reads Pair value as string
 func ReadPairValueString(reader io.Reader) (string, error) {
+	return ReadPairValueStringLimit(reader, limitString)
+}
+
+// Reads Pair value as string with given wait limit
+func ReadPairValueStringLimit(reader io.Reader, limit int) (string, error) {
 	var buffer []byte = make([]byte, 0, 0)
 	var escape bool = false
-	var limit int = limitString
 
 	for {
 		characterBuffer := make([]byte, 1)
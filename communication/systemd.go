@@ -0,0 +1,165 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// First descriptor passed by systemd socket activation (SD_LISTEN_FDS_START)
+const listenFdsStart = 3
+
+// Returns listening descriptors passed by systemd socket activation
+func ListenFds() ([]int, error) {
+	pidValue, pidPresent := os.LookupEnv("LISTEN_PID")
+	fdsValue, fdsPresent := os.LookupEnv("LISTEN_FDS")
+
+	// Socket activation is not used
+	if !pidPresent || !fdsPresent {
+		return nil, nil
+	}
+
+	// Variables are meant only for this process, do not pass them to children
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, errPid := strconv.Atoi(pidValue)
+
+	if errPid != nil {
+		return nil, errors.New("socket activation: LISTEN_PID is not number")
+	}
+
+	// Descriptors belong to another process
+	if pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, errCount := strconv.Atoi(fdsValue)
+
+	if errCount != nil || count < 0 {
+		return nil, errors.New("socket activation: LISTEN_FDS is not valid number")
+	}
+
+	fds := make([]int, 0, count)
+	for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+		// Do not leak descriptors to child processes
+		syscall.CloseOnExec(fd)
+		fds = append(fds, fd)
+	}
+
+	return fds, nil
+}
+
+// Prepares Server structure around already listening socket
+func InitFromDescriptor(masterSocket int) (*Server, error) {
+	fmt.Printf("Server initialization from socket descriptor %d started..\n", masterSocket)
+
+	// Check if descriptor is listening stream socket
+	socketType, errType := syscall.GetsockoptInt(masterSocket, syscall.SOL_SOCKET, syscall.SO_TYPE)
+
+	if errType != nil || socketType != syscall.SOCK_STREAM {
+		msg := fmt.Sprintf("Unable to initialize Server: Descriptor %d is not stream socket\n", masterSocket)
+		return nil, errors.New(msg)
+	}
+
+	accepting, errAccepting := syscall.GetsockoptInt(masterSocket, syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN)
+
+	if errAccepting != nil || accepting != 1 {
+		msg := fmt.Sprintf("Unable to initialize Server: Descriptor %d is not listening\n", masterSocket)
+		return nil, errors.New(msg)
+	}
+
+	// Create Server context
+	serverContext := Server{
+		masterSocket:   masterSocket,
+		Clients:        make(map[int]*Client),
+		WaitGroup:      sync.WaitGroup{},
+		MessageChannel: nil,
+		NextClientID:   1,
+	}
+
+	// Inform terminal
+	fmt.Printf("Server initialization completed\n")
+
+	// Return Server context
+	return &serverContext, nil
+}
+
+// Sends state notification to service manager, does nothing when NOTIFY_SOCKET is not set
+func Notify(state string) error {
+	socketPath, socketPresent := os.LookupEnv("NOTIFY_SOCKET")
+
+	if !socketPresent || socketPath == "" {
+		return nil
+	}
+
+	notifySocket, errSocket := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+
+	if errSocket != nil {
+		return errors.New("notify: could not create socket")
+	}
+
+	defer syscall.Close(notifySocket)
+
+	// Names starting with @ are translated to abstract namespace
+	address := &syscall.SockaddrUnix{Name: socketPath}
+
+	errSend := syscall.Sendto(notifySocket, []byte(state), 0, address)
+
+	if errSend != nil {
+		return fmt.Errorf("notify: could not send state: %s", errSend.Error())
+	}
+
+	return nil
+}
+
+// Returns how often watchdog notification has to be sent, false if watchdog is not enabled
+func WatchdogInterval() (time.Duration, bool) {
+	usecValue, usecPresent := os.LookupEnv("WATCHDOG_USEC")
+
+	if !usecPresent {
+		return 0, false
+	}
+
+	// Watchdog may be meant for another process
+	pidValue, pidPresent := os.LookupEnv("WATCHDOG_PID")
+
+	if pidPresent {
+		pid, errPid := strconv.Atoi(pidValue)
+		if errPid != nil || pid != os.Getpid() {
+			return 0, false
+		}
+	}
+
+	usec, errUsec := strconv.ParseInt(usecValue, 10, 64)
+
+	if errUsec != nil || usec <= 0 {
+		return 0, false
+	}
+
+	// Notify twice per watchdog period
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
+
+// Periodically sends watchdog keepalive to service manager
+func Watchdog(serverContext *Server) {
+	defer serverContext.WaitGroup.Done()
+
+	interval, enabled := WatchdogInterval()
+
+	if !enabled {
+		return
+	}
+
+	fmt.Printf("Watchdog enabled: notifying every %s\n", interval)
+
+	for {
+		time.Sleep(interval)
+		_ = Notify("WATCHDOG=1")
+	}
+}
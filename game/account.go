@@ -0,0 +1,446 @@
+package game
+
+import (
+	"../communication"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// Shortest allowed password
+	minPasswordLength = 8
+	// Longest allowed password, codec limits values anyway
+	maxAccountPasswordLength = 128
+	// Accounts file used when none is configured
+	defaultAccountsFile = "accounts.json"
+	// Passwords hashed at once, every hash takes tens of milliseconds and 16 MiB of memory
+	passwordWorkers = 4
+	// Passwords waiting for worker or being hashed, more requests are refused
+	passwordJobs = 32
+	// Failed logins allowed before player has to wait
+	loginFreeAttempts = 3
+	// Wait after first failed login over free attempts, doubles with every next failure
+	loginBackoffBase = time.Second
+	// Longest wait between login attempts
+	loginBackoffMax = 5 * time.Minute
+	// How long failed logins to account are remembered
+	loginFailuresMemory = 15 * time.Minute
+)
+
+// Account was not found in storage
+var ErrAccountNotFound = errors.New("account does not exist")
+
+// Account already exists in storage
+var ErrAccountExists = errors.New("account already exists")
+
+// Name or password given at login is wrong
+var ErrWrongCredentials = errors.New("wrong name or password")
+
+// Persistent account of player, outlives players connection and server restart
+type Account struct {
+	Name string `json:"name"`
+	// Salted password hash with its parameters
+	PasswordHash string `json:"passwordHash"`
+	// Skill rating used by matchmaking
+	Rating int `json:"rating"`
+	// Time when account was registered
+	Created time.Time `json:"created"`
+	// Time of last successful login
+	LastLogin time.Time `json:"lastLogin"`
+}
+
+// Result of register or login computed by password worker
+type AccountResult struct {
+	// Request which started work
+	Message communication.Message
+	// Player who sent request
+	PlayerID int
+	// Name of account
+	Name string
+	// Created or logged in account, nil on error
+	Account *Account
+	Err     error
+}
+
+// Failed logins of player or to account
+type LoginFailures struct {
+	Count int
+	// Time of last failed login
+	Last time.Time
+	// Time when next login can be tried
+	Until time.Time
+}
+
+// Storage of accounts
+type AccountStorage interface {
+	// Returns account with given name or ErrAccountNotFound
+	Load(name string) (*Account, error)
+	// Stores new account, returns ErrAccountExists when name is taken
+	Create(account *Account) error
+	// Stores changes of existing account
+	Save(account *Account) error
+}
+
+// Account storage kept in single JSON file
+type FileAccountStorage struct {
+	path     string
+	accounts map[string]Account
+	// Games save ratings from their own goroutines
+	lock sync.Mutex
+}
+
+// Opens accounts file, file is created with first account
+func NewFileAccountStorage(path string) (*FileAccountStorage, error) {
+	storage := &FileAccountStorage{
+		path:     path,
+		accounts: make(map[string]Account),
+	}
+
+	data, errRead := ioutil.ReadFile(path)
+
+	if os.IsNotExist(errRead) {
+		return storage, nil
+	}
+
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	errDecode := json.Unmarshal(data, &storage.accounts)
+
+	if errDecode != nil {
+		return nil, errDecode
+	}
+
+	return storage, nil
+}
+
+// Returns path of accounts file from PONG_ACCOUNTS_FILE or default path
+func AccountsFilePath() string {
+	path := os.Getenv("PONG_ACCOUNTS_FILE")
+
+	if path == "" {
+		return defaultAccountsFile
+	}
+
+	return path
+}
+
+func (storage *FileAccountStorage) Load(name string) (*Account, error) {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+
+	account, exist := storage.accounts[name]
+
+	if !exist {
+		return nil, ErrAccountNotFound
+	}
+
+	return &account, nil
+}
+
+func (storage *FileAccountStorage) Create(account *Account) error {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+
+	_, exist := storage.accounts[account.Name]
+
+	if exist {
+		return ErrAccountExists
+	}
+
+	storage.accounts[account.Name] = *account
+
+	errWrite := storage.write()
+
+	if errWrite != nil {
+		delete(storage.accounts, account.Name)
+	}
+
+	return errWrite
+}
+
+func (storage *FileAccountStorage) Save(account *Account) error {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+
+	_, exist := storage.accounts[account.Name]
+
+	if !exist {
+		return ErrAccountNotFound
+	}
+
+	storage.accounts[account.Name] = *account
+
+	return storage.write()
+}
+
+// Writes all accounts to file, file is replaced at once so crash does not leave it half written
+func (storage *FileAccountStorage) write() error {
+	data, errEncode := json.MarshalIndent(storage.accounts, "", "\t")
+
+	if errEncode != nil {
+		return errEncode
+	}
+
+	temporary, errCreate := ioutil.TempFile(filepath.Dir(storage.path), filepath.Base(storage.path)+".*")
+
+	if errCreate != nil {
+		return errCreate
+	}
+
+	_, errWrite := temporary.Write(data)
+	errClose := temporary.Close()
+
+	if errWrite == nil {
+		errWrite = errClose
+	}
+
+	if errWrite == nil {
+		errWrite = os.Chmod(temporary.Name(), 0600)
+	}
+
+	if errWrite != nil {
+		_ = os.Remove(temporary.Name())
+		return errWrite
+	}
+
+	return os.Rename(temporary.Name(), storage.path)
+}
+
+// Checks password of new account
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxAccountPasswordLength {
+		return fmt.Errorf("password must have %d to %d characters", minPasswordLength, maxAccountPasswordLength)
+	}
+
+	return nil
+}
+
+// Hashes password and stores new account, runs on password worker
+func CreateAccount(storage AccountStorage, name string, password string) (*Account, error) {
+	hash, errHash := HashPassword(password)
+
+	if errHash != nil {
+		return nil, errHash
+	}
+
+	account := &Account{
+		Name:         name,
+		PasswordHash: hash,
+		Rating:       initialRating,
+		Created:      time.Now(),
+		LastLogin:    time.Now(),
+	}
+
+	errCreate := storage.Create(account)
+
+	if errCreate != nil {
+		return nil, errCreate
+	}
+
+	return account, nil
+}
+
+// Checks credentials and returns account, runs on password worker
+func CheckAccount(storage AccountStorage, name string, password string) (*Account, error) {
+	account, errLoad := storage.Load(name)
+
+	if errLoad == ErrAccountNotFound {
+		return nil, ErrWrongCredentials
+	}
+
+	if errLoad != nil {
+		return nil, errLoad
+	}
+
+	valid, errVerify := VerifyPassword(password, account.PasswordHash)
+
+	if errVerify != nil {
+		return nil, errVerify
+	}
+
+	if !valid {
+		return nil, ErrWrongCredentials
+	}
+
+	account.LastLogin = time.Now()
+	_ = storage.Save(account)
+
+	return account, nil
+}
+
+// Binds account to player
+func BindAccount(player *Player, account *Account) {
+	player.account = account
+	player.userName = account.Name
+	player.rating = account.Rating
+}
+
+// Starts creating account for player, reply is sent once password is hashed
+func RegisterAccount(manager *Manager, player *Player, message *communication.Message, name string, password string) error {
+	if manager.Accounts == nil {
+		return errors.New("accounts are not available")
+	}
+
+	errPassword := validatePassword(password)
+
+	if errPassword != nil {
+		return errPassword
+	}
+
+	errStart := StartPasswordWork(manager, player, message, name, func() (*Account, error) {
+		return CreateAccount(manager.Accounts, name, password)
+	})
+
+	if errStart != nil {
+		return errStart
+	}
+
+	// Name is reserved until account is created
+	manager.pendingNames[name] = true
+
+	return nil
+}
+
+// Starts checking credentials of player, reply is sent once password is verified
+func LoginAccount(manager *Manager, player *Player, message *communication.Message, name string, password string) error {
+	if manager.Accounts == nil {
+		return errors.New("accounts are not available")
+	}
+
+	wait := LoginWait(manager, player, name)
+
+	if wait > 0 {
+		return fmt.Errorf("too many failed logins, try again in %d s", int(math.Ceil(wait.Seconds())))
+	}
+
+	return StartPasswordWork(manager, player, message, name, func() (*Account, error) {
+		return CheckAccount(manager.Accounts, name, password)
+	})
+}
+
+// Runs password work on worker goroutine and hands result to manager, fails when too much work waits
+func StartPasswordWork(manager *Manager, player *Player, message *communication.Message, name string, work func() (*Account, error)) error {
+	if player.authPending {
+		return errors.New("previous request is still processed")
+	}
+
+	select {
+	case manager.passwordJobs <- struct{}{}:
+	default:
+		return errors.New("server is busy, try again later")
+	}
+
+	player.authPending = true
+
+	result := &AccountResult{
+		Message:  *message,
+		PlayerID: player.ID,
+		Name:     name,
+	}
+
+	go func() {
+		manager.passwordWorkers <- struct{}{}
+		result.Account, result.Err = work()
+		<-manager.passwordWorkers
+		<-manager.passwordJobs
+		manager.accountResults <- result
+	}()
+
+	return nil
+}
+
+// Finishes register or login with result of password worker (called only from manager goroutine)
+func FinishAccountWork(manager *Manager, result *AccountResult) {
+	if result.Message.Msg == actionRegister {
+		delete(manager.pendingNames, result.Name)
+	}
+
+	// Player could leave while password was hashed
+	player, exist := manager.Players[result.PlayerID]
+
+	if !exist {
+		return
+	}
+
+	player.authPending = false
+
+	if result.Message.Msg == actionRegister {
+		_ = FinishRegister(manager, player, result)
+	} else {
+		_ = FinishLogin(manager, player, result)
+	}
+}
+
+// Returns how long player has to wait before next login to account
+func LoginWait(manager *Manager, player *Player, name string) time.Duration {
+	wait := time.Until(player.loginFailures.Until)
+
+	accountFailures, exist := manager.loginFailures[name]
+
+	if exist && time.Until(accountFailures.Until) > wait {
+		wait = time.Until(accountFailures.Until)
+	}
+
+	return wait
+}
+
+// Counts failed login of player to account, waiting gets longer with every failure
+func RecordLoginFailure(manager *Manager, player *Player, name string) {
+	accountFailures, exist := manager.loginFailures[name]
+
+	if !exist {
+		accountFailures = &LoginFailures{}
+		manager.loginFailures[name] = accountFailures
+	}
+
+	for _, failures := range []*LoginFailures{&player.loginFailures, accountFailures} {
+		failures.Count++
+		failures.Last = time.Now()
+
+		if failures.Count < loginFreeAttempts {
+			continue
+		}
+
+		delay := loginBackoffBase << uint(failures.Count-loginFreeAttempts)
+
+		if delay <= 0 || delay > loginBackoffMax {
+			delay = loginBackoffMax
+		}
+
+		failures.Until = time.Now().Add(delay)
+	}
+}
+
+// Forgets failed logins after successful login
+func ResetLoginFailures(manager *Manager, player *Player, name string) {
+	player.loginFailures = LoginFailures{}
+	delete(manager.loginFailures, name)
+}
+
+// Forgets failed logins of accounts nobody tried for long time
+func PruneLoginFailures(manager *Manager) {
+	for name, failures := range manager.loginFailures {
+		if time.Since(failures.Last) > loginFailuresMemory && time.Now().After(failures.Until) {
+			delete(manager.loginFailures, name)
+		}
+	}
+}
+
+// Stores current rating of player to his account
+func SaveAccountRating(manager *Manager, player *Player) {
+	if manager.Accounts == nil || player == nil || player.account == nil {
+		return
+	}
+
+	player.account.Rating = player.rating
+	_ = manager.Accounts.Save(player.account)
+}
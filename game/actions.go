@@ -4,7 +4,6 @@ import (
 	"../communication"
 	"errors"
 	"fmt"
-	"strconv"
 	"time"
 )
 
@@ -21,28 +20,64 @@ const (
 	actionDisconnect = 20
 	// Users request to register playername
 	actionRegister = 1000
+	// Player logs in to his account
+	actionLogin = 1001
 	// Users keepAlive message
 	actionKeepAlive = 1100
 	// Users request to create game
 	actionCreateGame = 2000
 	// Users request to join game
 	actionJoinGame = 2100
+	// Server informs game members that player joined, left or reconnected
+	actionGameMembers = 2101
 	// Users request to reconnect to existing game
 	actionReconnectGame = 2200
 	// Users request to list free games
 	actionListGames = 2300
+	// Player subscribes or unsubscribes lobby events
+	actionLobbySubscribe = 2301
+	// Server informs subscribed players that game was created, updated or removed
+	actionLobbyEvent = 2302
 	// Player ask for current game state (server can send it by himself)
 	actionGameState = 2400
 	// Player demands game abandon
 	actionGameAbandon = 2500
+	// Player wants to watch game
+	actionSpectate = 2600
+	// Server informs about change of game state
+	actionGameStateChange = 2700
+	// Player is ready to start game
+	actionReady = 2800
+	// Player wants to be matched with opponent
+	actionQuickPlay = 2900
 
 	// ######################################################################
 	// INGAME MESSAGES
 
 	// Player enters his movement
 	actionPlayerPositionUpdate = 3000
+	// Player enters his movement as command
+	actionPlayerInput = 3001
 	// Game ends
 	actionGameEnd = 3100
+	// Player wants to pause or resume game
+	actionPause = 3200
+	// Player offers, accepts or declines rematch of finished game
+	actionRematch = 3300
+	// Server informs about next game of series
+	actionSeriesGame = 3400
+	// Player challenges other player, accepts, declines or cancels challenge
+	actionChallenge = 3500
+
+	// ######################################################################
+	// CHAT MESSAGES
+
+	// Player sends chat message
+	actionChat = 4000
+	// Server delivers chat message
+	actionChatMessage = 4001
+	// Player mutes or blocks other player
+	actionChatIgnore = 4002
 )
 
 // Initialize available actions
@@ -52,6 +87,7 @@ func InitializeActions(manager *Manager) error {
 
 	// Register global action messages
 	manager.ServerActions.global[actionRegister] = RegisterAction
+	manager.ServerActions.global[actionLogin] = LoginAction
 	manager.ServerActions.global[actionCreateGame] = CreateGameAction
 	manager.ServerActions.global[actionJoinGame] = JoinGameAction
 	manager.ServerActions.global[actionListGames] = GetGamesListAction
@@ -59,9 +95,19 @@ func InitializeActions(manager *Manager) error {
 	manager.ServerActions.global[actionGameAbandon] = AbandonAction
 	manager.ServerActions.global[actionKeepAlive] = KeepAliveAction
 	manager.ServerActions.global[actionReconnectGame] = ReconnectAction
+	manager.ServerActions.global[actionSpectate] = SpectateAction
+	manager.ServerActions.global[actionRematch] = RematchAction
+	manager.ServerActions.global[actionQuickPlay] = QuickPlayAction
+	manager.ServerActions.global[actionChallenge] = ChallengeAction
+	manager.ServerActions.global[actionLobbySubscribe] = LobbySubscribeAction
+	manager.ServerActions.global[actionChat] = ChatAction
+	manager.ServerActions.global[actionChatIgnore] = ChatIgnoreAction
 
 	// Register game forward messages
 	manager.ServerActions.game[actionPlayerPositionUpdate] = nil
+	manager.ServerActions.game[actionPlayerInput] = nil
+	manager.ServerActions.game[actionPause] = nil
+	manager.ServerActions.game[actionReady] = nil
 	manager.ServerActions.game[actionGameState] = nil
 
 	return nil
@@ -96,13 +142,16 @@ func ProcessMessage(manager *Manager, message *communication.Message) error {
 
 	if ok {
 		_ = manager.ServerActions.global[message.Msg](manager, message)
+
+		// Global actions create, join and leave games
+		PublishLobbyChanges(manager)
 	} else {
 		// Redirect action to game server
 		if okGame {
-			game, errGameMessageCheck := CheckGameAction(manager, message)
+			game, player, errGameMessageCheck := CheckGameAction(manager, message)
 
 			if errGameMessageCheck == nil {
-				game.Messages = append(game.Messages, message)
+				game.Messages = append(game.Messages, &GameMessage{Player: player, Message: message})
 			}
 
 		} else {
@@ -142,6 +191,14 @@ func ReconnectAction(manager *Manager, message *communication.Message) error {
 		return errors.New("reconnect: imcomplete message - missing username")
 	}
 
+	tokenValue, tokenPresent := message.Content["token"]
+
+	if !tokenPresent {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Reconnect - message incomplete - missing token;>", message.Rid,  actionReconnectGame))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("reconnect: imcomplete message - missing token")
+	}
+
 	// Get current client and update
 	client, errClient := communication.GetClientByID(manager.CommunicationServer, message.Source)
 
@@ -151,26 +208,60 @@ func ReconnectAction(manager *Manager, message *communication.Message) error {
 		return errors.New("reconnect: tcp client error")
 	}
 
-	var player *Player = nil
-	for _, pl := range manager.Players {
-		if pl.userName == playerNameValue {
-			player = pl
-			// Add new TCP client to player
-			player.client = client
-			player.userName = playerNameValue
-			break
-		}
-	}
-
-	println("Player found, fetching info")
+	player, errFindPlayer := GetPlayerByName(manager, playerNameValue)
 
 	// Player with such name was not found
-	if player == nil {
+	if errFindPlayer != nil {
 		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Reconnect - user does not exist;>", message.Rid,  actionReconnectGame))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 		return errors.New("reconnect: player does not exist")
 	}
 
+	// Only holder of session token can take player over
+	errSession := CheckSessionToken(manager, player, tokenValue)
+
+	if errSession != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Reconnect - %s;>", message.Rid,  actionReconnectGame, errSession.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("reconnect: %s", errSession.Error())
+	}
+
+	// Player created for new client before reconnect is replaced by reconnected player
+	placeholder, errPlaceholder := GetPlayerByClientID(manager, message.Source)
+
+	if errPlaceholder == nil && placeholder.ID != player.ID {
+		if isAuthenticated(placeholder) {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Reconnect - already registered as other user;>", message.Rid,  actionReconnectGame))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("reconnect: client is registered as other user")
+		}
+
+		_ = RemovePlayer(manager, placeholder)
+	}
+
+	// Old connection loses player
+	if player.client != nil && player.client.UID != client.UID {
+		oldClient, errOldClient := communication.GetClientByID(manager.CommunicationServer, player.client.UID)
+
+		if errOldClient == nil {
+			data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:error;msg:Session was taken over by other connection;>", actionReconnectGame))
+			_ = communication.SendID(manager.CommunicationServer, data, oldClient.UID)
+			_ = communication.RemoveClient(manager.CommunicationServer, oldClient.Socket)
+		}
+	}
+
+	// Add new TCP client to player and rotate token
+	player.client = client
+	player.lastCommunication = time.Now().Unix()
+
+	token, errToken := IssueSessionToken(player)
+
+	if errToken != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Reconnect - %s;>", message.Rid,  actionReconnectGame, errToken.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("reconnect: %s", errToken.Error())
+	}
+
 	// Check if player has game
 	game, errGame := GetGameByPlayerID(manager, player.ID)
 	fmt.Printf("game: %v\n", game)
@@ -178,23 +269,23 @@ func ReconnectAction(manager *Manager, message *communication.Message) error {
 
 	if game == nil && errGame != nil {
 		// Player does not have game
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;playerID:%d;gameID:-1;>", message.Rid,  actionReconnectGame, player.ID))
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;playerID:%d;gameID:-1;token:%s;>", message.Rid,  actionReconnectGame, player.ID, token))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 	} else {
 		// Player has game
 		playAs := "3"
 
 		// Determine who player is playing as
-		if game.Player1.ID == player.ID {
+		if game.Player1 != nil && game.Player1.ID == player.ID {
 			playAs = "1"
 		}
 
-		if game.Player2.ID == player.ID {
+		if game.Player2 != nil && game.Player2.ID == player.ID {
 			playAs = "2"
 		}
 
 		// Send info
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;playerID:%d;gameID:%d;playas:%s;>", message.Rid,  actionReconnectGame, player.ID, game.UID, playAs))
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;playerID:%d;gameID:%d;playas:%s;token:%s;>", message.Rid,  actionReconnectGame, player.ID, game.UID, playAs, token))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 	}
 
@@ -247,34 +338,28 @@ func AbandonAction(manager *Manager, message *communication.Message) error {
 		return errors.New("abandon: message cannot be nil")
 	}
 
-	playerIDValue, playerIDPresent := message.Content["playerID"]
+	// Player is given by connection, playerID only has to match it
+	player, errFindPlayer := ResolveActingPlayer(manager, message)
 
-	if !playerIDPresent {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot abandon game - message incomplete - playerID;>", message.Rid,  actionGameAbandon))
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot abandon game - %s;>", message.Rid, actionGameAbandon, errFindPlayer.Error()))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("cannot abandon game: playerID not provided")
+		return fmt.Errorf("cannot abandon game: %s", errFindPlayer.Error())
 	}
 
-	playerID, errParseInt :=  strconv.Atoi(playerIDValue)
-
-	if errParseInt != nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot abandon game - playerID must be number;>", message.Rid,  actionGameAbandon))
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot abandon game - player is not registered;>", message.Rid, actionGameAbandon))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("cannot abandon game: playerID is not number")
+		return errors.New("cannot abandon game: player is not registered")
 	}
 
-	player, errFindPlayer := GetPlayerByID(manager, playerID)
+	// Spectator just stops watching
+	spectatedGame, errSpectated := StopSpectating(manager, player)
 
-	if errFindPlayer != nil || player == nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot abandon game - player does not exist;>", message.Rid, actionGameAbandon))
-		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("cannot abandon game: player does not exist")
-	}
-
-	if !isAuthenticated(player) {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot abandon game - player is not registered;>", message.Rid, actionGameAbandon))
+	if errSpectated == nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Spectating of game #%d stopped;>", message.Rid, actionGameAbandon, spectatedGame.UID))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("cannot abandon game: player is not registered")
+		return nil
 	}
 
 	game, errGame := GetPlayersGame(manager, player)
@@ -291,20 +376,25 @@ func AbandonAction(manager *Manager, message *communication.Message) error {
 		return errors.New("cannot abandon game: no game found")
 	}
 
+	side := PlayerSide(game, player)
+
 	if game.Player1 != nil && game.Player1.ID == player.ID {
 		game.Player1 = nil
-		game.Paused = true
 		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Game abandoned;>", message.Rid, actionGameAbandon))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 	}
 
 	if game.Player2 != nil && game.Player2.ID == player.ID {
 		game.Player2 = nil
-		game.Paused = true
 		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Game abandoned;>", message.Rid, actionGameAbandon))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 	}
 
+	NotifyGameMembers(manager, game, player, side, memberLeft)
+
+	// Bots do not play alone
+	RemoveLonelyBots(game)
+
 	// Check if both players are gone, if so, stop game
 	if game.Player1 == nil && game.Player2 == nil {
 		_ = RemoveEmptyGame(manager, game)
@@ -323,51 +413,42 @@ func DisconnectAction(manager *Manager, message *communication.Message) error {
 		return errors.New("disconnect: message cannot be nil")
 	}
 
-	playerIDValue, playerIDPresent := message.Content["playerID"]
-
-	if !playerIDPresent {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Account not terminated - playerID not provided;>", message.Rid, actionDisconnect))
-		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("could not terminate users account: playerID not provided")
-	}
-
-	playerID, errParseInt :=  strconv.Atoi(playerIDValue)
+	// Player is given by connection, playerID only has to match it
+	player, errFindPlayer := ResolveActingPlayer(manager, message)
 
-	if errParseInt != nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Account not terminated - playerID must be number;>", message.Rid, actionDisconnect))
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Account not terminated - %s;>", message.Rid, actionDisconnect, errFindPlayer.Error()))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("could not terminate users account: playerID is not number")
+		return fmt.Errorf("unable to terminate players account: %s", errFindPlayer.Error())
 	}
 
-	player, errFindPlayer := GetPlayerByID(manager, playerID)
-
-	if errFindPlayer != nil || player == nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Account not terminated - player does not exist;>", message.Rid, actionDisconnect))
-		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("unable to terminate players account")
-	}
+	// Stop spectating
+	_, _ = StopSpectating(manager, player)
 
 	// Terminate players game if hes alone in game
 	game, errGame := GetPlayersGame(manager, player)
 
 	// Game exist
 	if errGame == nil {
+		side := PlayerSide(game, player)
+
 		// Player is player 1
 		if game.Player1 != nil && game.Player1.ID == player.ID {
 			// Delete player from game
 			game.Player1 = nil
-			// Mark game as paused
-			game.Paused = true
 		}
 
 		// Player is player 2
 		if game.Player2 != nil && game.Player2.ID == player.ID {
 			// Delete player from game
 			game.Player2 = nil
-			// Mark game as paused
-			game.Paused = true
 		}
 
+		NotifyGameMembers(manager, game, player, side, memberLeft)
+
+		// Bots do not play alone
+		RemoveLonelyBots(game)
+
 		// Check if both players are gone, if so, stop game
 		if game.Player1 == nil && game.Player2 == nil {
 			_ = RemoveEmptyGame(manager, game)
@@ -382,34 +463,43 @@ func DisconnectAction(manager *Manager, message *communication.Message) error {
 	}
 
 	_ = RemovePlayer(manager, player)
-	fmt.Printf("Player #%d: Account and connection terminated\n", playerID)
+	fmt.Printf("Player #%d: Account and connection terminated\n", player.ID)
 
 	return nil
 }
 
-// Checks message for game before its redirected to game
-func CheckGameAction(manager *Manager, message *communication.Message) (*GameServer,error) {
+// Checks message for game before its redirected to game, returns game and player who sent message
+func CheckGameAction(manager *Manager, message *communication.Message) (*GameServer, *Player, error) {
 	if manager == nil {
-		return nil, errors.New("unable to check game message - manager cannot be nil")
+		return nil, nil, errors.New("unable to check game message - manager cannot be nil")
 	}
 
 	if message == nil {
-		return nil, errors.New("unable to check game message - message cannot be nil")
+		return nil, nil, errors.New("unable to check game message - message cannot be nil")
 	}
 
-	// Check if player is registered
-	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+	// Player is given by connection, playerID only has to match it
+	player, errFindPlayer := ResolveActingPlayer(manager, message)
 
 	if errFindPlayer != nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:User does not exist;>", message.Rid, message.Msg))
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:%s;>", message.Rid, message.Msg, errFindPlayer.Error()))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return nil, errors.New("game message check: user does not exist")
+		return nil, nil, fmt.Errorf("game message check: %s", errFindPlayer.Error())
 	}
 
 	if !isAuthenticated(player) {
 		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:User is not registered;>", message.Rid, message.Msg))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return nil, errors.New("game message check: user is not registered")
+		return nil, nil, errors.New("game message check: user is not registered")
+	}
+
+	// Spectators only watch
+	_, errSpectating := GetSpectatedGame(manager, player)
+
+	if errSpectating == nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Spectators cannot control game;>", message.Rid, message.Msg))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return nil, nil, errors.New("game message check: user is spectator")
 	}
 
 	// Check if player has game
@@ -418,11 +508,11 @@ func CheckGameAction(manager *Manager, message *communication.Message) (*GameSer
 	if errGameExist != nil {
 		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:User does not have game;>", message.Rid, message.Msg))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return nil, errors.New("game message check: user does not have game")
+		return nil, nil, errors.New("game message check: user does not have game")
 	}
 
 	// User is registed and in game
-	return game, nil
+	return game, player, nil
 }
 
 // Function to register player's account
@@ -453,6 +543,17 @@ func RegisterAction(manager *Manager, message *communication.Message) error {
 		}
 	}
 
+	// Name of account which is being created is taken too
+	if manager.pendingNames[username] {
+		nameExist = true
+	}
+
+	// Name of account belongs to its owner even when he is offline
+	if !nameExist && manager.Accounts != nil {
+		_, errAccount := manager.Accounts.Load(username)
+		nameExist = errAccount == nil
+	}
+
 	if nameExist == true {
 		// Name was given but name is already used
 		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:err;msg:username is taken;>", message.Rid))
@@ -475,14 +576,168 @@ func RegisterAction(manager *Manager, message *communication.Message) error {
 			return errors.New("user could not be registered: cannot register twice")
 		}
 
+		// Login or register of player is still processed
+		if player.authPending {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:err;msg:previous request is still processed;>", message.Rid))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("user could not be registered: previous request is still processed")
+		}
+
+		// Password creates persistent account, without it player is guest
+		password, passwordPresent := message.Content["password"]
+
+		if passwordPresent {
+			// Hashing password takes long, reply is sent once account is created
+			errAccount := RegisterAccount(manager, player, message, username, password)
+
+			if errAccount != nil {
+				data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:err;msg:%s;>", message.Rid, errAccount.Error()))
+				_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+				return fmt.Errorf("user could not be registered: %s", errAccount.Error())
+			}
+
+			return nil
+		}
+
+		// Token lets player reconnect later
+		token, errToken := IssueSessionToken(player)
+
+		if errToken != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:err;msg:%s;>", message.Rid, errToken.Error()))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return fmt.Errorf("user could not be registered: %s", errToken.Error())
+		}
+
 		player.userName = username
 		// User successfully registered
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:ok;msg:user registered;playerID:%d;>", message.Rid, player.ID))
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:ok;msg:user registered;playerID:%d;account:false;rating:%d;token:%s;>", message.Rid, player.ID, player.rating, token))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 		return nil
 	}
 }
 
+// Function to log in to persistent account
+func LoginAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("login: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("login: message cannot be nil")
+	}
+
+	username, namePresent := message.Content["name"]
+	password, passwordPresent := message.Content["password"]
+
+	if !namePresent || !passwordPresent {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:request data are missing;>", message.Rid, actionLogin))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("login: request data are missing")
+	}
+
+	player, errPlayerExist := GetPlayerByClientID(manager, message.Source)
+
+	if errPlayerExist != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:could not create player;>", message.Rid, actionLogin))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("login: player is nil")
+	}
+
+	if isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:You are already logged in;>", message.Rid, actionLogin))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("login: already logged in")
+	}
+
+	// Account can be used by single player at once
+	_, errOnline := GetPlayerByName(manager, username)
+
+	if errOnline == nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:account is already in use;>", message.Rid, actionLogin))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("login: account is already in use")
+	}
+
+	// Verifying password takes long, reply is sent once it is done
+	errLogin := LoginAccount(manager, player, message, username, password)
+
+	if errLogin != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:%s;>", message.Rid, actionLogin, errLogin.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("login: %s", errLogin.Error())
+	}
+
+	return nil
+}
+
+// Replies to register with password once account is created
+func FinishRegister(manager *Manager, player *Player, result *AccountResult) error {
+	message := &result.Message
+
+	if result.Err != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:err;msg:%s;>", message.Rid, result.Err.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("user could not be registered: %s", result.Err.Error())
+	}
+
+	// Token lets player reconnect later
+	token, errToken := IssueSessionToken(player)
+
+	if errToken != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:err;msg:%s;>", message.Rid, errToken.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("user could not be registered: %s", errToken.Error())
+	}
+
+	BindAccount(player, result.Account)
+
+	// User successfully registered
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:1000;|status:ok;msg:user registered;playerID:%d;account:true;rating:%d;token:%s;>", message.Rid, player.ID, player.rating, token))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+	return nil
+}
+
+// Replies to login once password is verified
+func FinishLogin(manager *Manager, player *Player, result *AccountResult) error {
+	message := &result.Message
+
+	if result.Err == ErrWrongCredentials {
+		RecordLoginFailure(manager, player, result.Name)
+	}
+
+	if result.Err != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:%s;>", message.Rid, actionLogin, result.Err.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("login: %s", result.Err.Error())
+	}
+
+	// Somebody else could log in to account meanwhile
+	_, errOnline := GetPlayerByName(manager, result.Name)
+
+	if errOnline == nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:account is already in use;>", message.Rid, actionLogin))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("login: account is already in use")
+	}
+
+	ResetLoginFailures(manager, player, result.Name)
+
+	// Token lets player reconnect later
+	token, errToken := IssueSessionToken(player)
+
+	if errToken != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:err;msg:%s;>", message.Rid, actionLogin, errToken.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("login: %s", errToken.Error())
+	}
+
+	BindAccount(player, result.Account)
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:user logged in;playerID:%d;account:true;rating:%d;token:%s;>", message.Rid, actionLogin, player.ID, player.rating, token))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+	return nil
+}
+
 // Function to create new game
 func CreateGameAction(manager *Manager, message *communication.Message) error {
 	if manager == nil {
@@ -514,8 +769,60 @@ func CreateGameAction(manager *Manager, message *communication.Message) error {
 		return errors.New("createGame: already in another game")
 	}
 
+	// Player stops watching other game
+	_, _ = StopSpectating(manager, player)
+
+	// Read optional rules
+	rules, errRules := ParseRules(message.Content)
+
+	if errRules != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:error;msg:Game not created - %s;>", message.Rid, errRules.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("createGame: %s", errRules.Error())
+	}
+
+	// Read optional seed
+	seed, seedPresent, errSeed := ParseSeed(message.Content)
+
+	if errSeed != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:error;msg:Game not created - %s;>", message.Rid, errSeed.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("createGame: %s", errSeed.Error())
+	}
+
+	if !seedPresent {
+		seed = time.Now().UnixNano()
+	}
+
+	// Read optional bot opponent
+	botDifficulty, botAfter, errBot := ParseBotSettings(message.Content)
+
+	if errBot != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:error;msg:Game not created - %s;>", message.Rid, errBot.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("createGame: %s", errBot.Error())
+	}
+
+	// Read optional series length
+	bestOf, errBestOf := ParseBestOf(message.Content)
+
+	if errBestOf != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:error;msg:Game not created - %s;>", message.Rid, errBestOf.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("createGame: %s", errBestOf.Error())
+	}
+
+	// Read optional privacy
+	privacy, errPrivacy := ParsePrivacy(manager, message.Content)
+
+	if errPrivacy != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:error;msg:Game not created - %s;>", message.Rid, errPrivacy.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("createGame: %s", errPrivacy.Error())
+	}
+
 	// Player exist so we can create game server for him
-	gameCreated,  errCreateGame := CreateGame(manager, player)
+	gameCreated,  errCreateGame := CreateConfiguredGame(manager, player, rules, seed)
 
 	if errCreateGame != nil {
 		msg := fmt.Sprintf("createGame: %s", errCreateGame.Error())
@@ -526,7 +833,18 @@ func CreateGameAction(manager *Manager, message *communication.Message) error {
 
 	// Game was created
 	gameCreated.Player1 = player
-	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:ok;msg:Game created and joined;GameID:%d;>", message.Rid, gameCreated.UID))
+	gameCreated.Privacy = privacy
+
+	// Game is first of series
+	if bestOf > 1 {
+		CreateSeries(manager, gameCreated, bestOf)
+	}
+
+	// Bot fills missing opponent
+	gameCreated.botDifficulty = botDifficulty
+	gameCreated.botAfter = botAfter
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:2000;|status:ok;msg:Game created and joined;GameID:%d;seed:%d;%s%s%s>", message.Rid, gameCreated.UID, gameCreated.Seed, BuildPrivacyContent(gameCreated), BuildSeriesContent(gameCreated), BuildRulesContent(gameCreated.Rules)))
 	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
 
 	// Start game
@@ -570,46 +888,46 @@ func JoinGameAction(manager *Manager, message *communication.Message) error {
 		return errors.New("joinGame: Player has already game")
 	}
 
-	// Check if message content contains game ID key
-	gameIDString, idPresent := message.Content["gameID"]
+	// Find game by ID or invite code
+	game, errFindGame := FindRequestedGame(manager, message.Content)
 
-	if !idPresent {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Game not joined - Missing game ID;>", message.Rid, actionJoinGame))
+	if errFindGame != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Game not joined - %s;>", message.Rid, actionJoinGame, errFindGame.Error()))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("joinGame: Missing game ID")
+		return fmt.Errorf("joinGame: %s", errFindGame.Error())
 	}
 
-	// Check if game ID is number
-	gameID, errParse := strconv.Atoi(gameIDString)
+	// Private game needs invite code
+	errAccess := CheckGameAccess(game, message.Content)
 
-	if errParse != nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Game not joined - Game ID needs to be number;>", message.Rid, actionJoinGame))
+	if errAccess != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Game not joined - %s;>", message.Rid, actionJoinGame, errAccess.Error()))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("joinGame: Game ID is not number")
+		return fmt.Errorf("joinGame: %s", errAccess.Error())
 	}
 
-	// Check if game exist
-	game, errFindGame := GetGameByID(manager, gameID)
-
-	if errFindGame != nil {
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Game not joined - Game with that ID does not exist;>", message.Rid, actionJoinGame))
-		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
-		return errors.New("joinGame: Game ID is not number")
-	}
+	// Player stops watching other game
+	_, _ = StopSpectating(manager, player)
 
 	// Assign player to game as Player1
 	if game.Player1 == nil {
 		game.Player1 = player
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Game #%d joined as Player #1;player:1;>", message.Rid, actionJoinGame, game.UID))
+		_ = PlacePlayer(game, player)
+		NotifyGameMembers(manager, game, player, 1, memberJoined)
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Game #%d joined as Player #1;GameID:%d;player:1;%s>", message.Rid, actionJoinGame, game.UID, game.UID, BuildRulesContent(game.Rules)))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		ReplayGameChat(manager, game, player)
 		return nil
 	}
 
 	// Assign player to game as Player2
 	if game.Player2 == nil {
 		game.Player2 = player
-		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Game #%d joined as Player #2;player:2;>", message.Rid, actionJoinGame, game.UID))
+		_ = PlacePlayer(game, player)
+		NotifyGameMembers(manager, game, player, 2, memberJoined)
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Game #%d joined as Player #2;GameID:%d;player:2;%s>", message.Rid, actionJoinGame, game.UID, game.UID, BuildRulesContent(game.Rules)))
 		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		ReplayGameChat(manager, game, player)
 		return nil
 	}
 
@@ -643,33 +961,44 @@ func GetGamesListAction(manager *Manager, message *communication.Message) error
 		return errors.New("listGames: User not registered")
 	}
 
-	// Create message base and format
-	gameCountFormat := "gameCount:%d;"
-	gameIDFormat := "gameID%d:%d;"
+	// Read filters and paging
+	query, errQuery := ParseGameListQuery(message.Content)
 
-	messageBase := fmt.Sprintf("<id:%d;rid:0;type:%d;|", message.Rid, actionListGames)
+	if errQuery != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot list games - %s;>", message.Rid, actionListGames, errQuery.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("listGames: %s", errQuery.Error())
+	}
 
-	// Determine count of empty games
-	var empty int = 0
-	for _, game := range manager.GameServers {
-		if game.Player2 == nil || game.Player1 == nil {
-			empty++
-		}
+	games := FilterGames(manager, query)
+
+	// Select requested page
+	first := (query.Page - 1) * query.PageSize
+	if first > len(games) {
+		first = len(games)
+	}
+
+	last := first + query.PageSize
+	if last > len(games) {
+		last = len(games)
 	}
 
+	page := games[first:last]
+	pages := (len(games) + query.PageSize - 1) / query.PageSize
+
+	messageBase := fmt.Sprintf("<id:%d;rid:0;type:%d;|", message.Rid, actionListGames)
+
 	// Build message
 	sendMessage := ""
 	sendMessage = sendMessage + messageBase
-	sendMessage = sendMessage + fmt.Sprintf(gameCountFormat, empty)
-
-	// Build game ids list
-	var id int = 0
-	for _, game := range manager.GameServers {
-		if game.Player2 == nil || game.Player1 == nil {
-			sendMessage = sendMessage + fmt.Sprintf(gameIDFormat, id, game.UID)
-			id++
-		}
+	sendMessage = sendMessage + fmt.Sprintf("gameCount:%d;", len(page))
+	sendMessage = sendMessage + fmt.Sprintf("total:%d;page:%d;pages:%d;pageSize:%d;filter:%s;", len(games), query.Page, pages, query.PageSize, query.Filter)
+
+	// Build games list
+	for id, game := range page {
+		sendMessage = sendMessage + BuildGameListEntry(game, id)
 	}
+
 	// Add end of message
 	sendMessage = sendMessage + ">"
 
@@ -678,8 +1007,72 @@ func GetGamesListAction(manager *Manager, message *communication.Message) error
 	return nil
 }
 
+// Function to watch running game
+func SpectateAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("spectate: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("spectate: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot spectate - User does not exist;>", message.Rid, actionSpectate))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("spectate: User does not exist")
+	}
+
+	// Check if player is registered
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot spectate - User is not registered;>", message.Rid, actionSpectate))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("spectate: User not registered")
+	}
+
+	// Players cannot watch other games while playing
+	_, errConnectedGames := GetPlayersGame(manager, player)
+
+	if errConnectedGames == nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot spectate - Already have game;>", message.Rid, actionSpectate))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("spectate: Player has already game")
+	}
 
+	// Find game by ID or invite code
+	game, errFindGame := FindRequestedGame(manager, message.Content)
 
+	if errFindGame != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot spectate - %s;>", message.Rid, actionSpectate, errFindGame.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("spectate: %s", errFindGame.Error())
+	}
+
+	// Private game needs invite code
+	errAccess := CheckGameAccess(game, message.Content)
+
+	if errAccess != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot spectate - %s;>", message.Rid, actionSpectate, errAccess.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("spectate: %s", errAccess.Error())
+	}
 
+	// Stop watching previous game
+	_, _ = StopSpectating(manager, player)
 
+	errAdd := AddSpectator(game, player)
+
+	if errAdd != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot spectate - %s;>", message.Rid, actionSpectate, errAdd.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("spectate: %s", errAdd.Error())
+	}
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Spectating game #%d;gameID:%d;playas:3;%s>", message.Rid, actionSpectate, game.UID, game.UID, BuildRulesContent(game.Rules)))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+	ReplayGameChat(manager, game, player)
+	return nil
+}
 
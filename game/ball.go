@@ -2,17 +2,22 @@ package game
 
 import (
 	"errors"
-	"math"
-	"math/rand"
 )
 
 type Ball struct {
-	X float64		// X-axis coordination
-	Y float64		// Y-axis coordination
-	Rotation int 	// Degrees of rotation 0-359
-	Speed int		// Speed of ball in pixels per Tick
-	MaxSpeed int	// Maximum speed of ball in pixels per tick
-	Size int		// Ball diameter (circle radius) (eg. 1/2 of diameter)
+	X float64			// X-axis coordination
+	Y float64			// Y-axis coordination
+	Direction Vector	// Unit vector of movement
+	Rotation int 		// Degrees of rotation 0-359 (derived from Direction, sent to clients)
+	Speed float64		// Speed of ball in pixels per Tick
+	MaxSpeed float64	// Maximum speed of ball in pixels per tick
+	Size int			// Ball diameter (circle radius) (eg. 1/2 of diameter)
+}
+
+// Sets direction of ball by rotation in degrees
+func SetBallRotation(ball *Ball, rotation int) {
+	ball.Direction = VectorFromDegrees(float64(rotation))
+	ball.Rotation = DegreesFromVector(ball.Direction)
 }
 
 func isRotatedLeft(rotation int) bool {
@@ -42,139 +47,55 @@ func UpdateBall(server *GameServer) error {
 	if server.Ball == nil {
 		return errors.New("unable to update ball - ball cannot be null")
 	}
-	radians := float64(server.Ball.Rotation) * (math.Pi / 180)
-	velocityX := math.Cos(radians)
-	velocityY := math.Sin(radians)
-
-
-	if velocityX > 0 {
-		// Move right
-		velocityRight := math.Abs(velocityX * float64(server.Ball.Speed))
-		if server.Ball.X + velocityRight + float64(server.Ball.Size) > float64(server.WIDTH) {
-			// Stop at boundary
-			server.Ball.X = float64(server.WIDTH) - float64(server.Ball.Size)
-			// Bounce
-			server.Ball.Rotation = int(math.Mod(float64(180 - server.Ball.Rotation), 360))
-		} else {
-			// Move normally
-			server.Ball.X += velocityRight
-		}
-	} else {
-		velocityLeft := math.Abs(velocityX * float64(server.Ball.Speed))
-		if server.Ball.X - velocityLeft - float64(server.Ball.Size) < 0 {
-			// Stop at boundary
-			server.Ball.X = 0 + float64(server.Ball.Size)
-			// Bounce
-			server.Ball.Rotation = int(math.Mod(float64(180 - server.Ball.Rotation), 360))
-		} else {
-			// Move normally
-			server.Ball.X -= velocityLeft
-		}
-	}
 
-	if velocityY > 0 {
-		// Move down
-		velocityDown := math.Abs(velocityY * float64(server.Ball.Speed))
-		if server.Player2 != nil {
-			// Player2 joined - check his boundary
-			if InRangeAround(server.Ball.X, server.Player2.x, float64(server.Player2.width) / 2 + float64(server.Ball.Size), float64(server.Player2.width) / 2 + float64(server.Ball.Size)) {
-				// Ball is in range of player - cautious move
-				if server.Ball.Y + velocityDown + float64(server.Ball.Size) > server.Player2.y {
-					// Stop at players location
-					server.Ball.Y = server.Player2.y - float64(server.Ball.Size)
-					// Bounce
-					server.Ball.Rotation = int(math.Mod(float64(360 - server.Ball.Rotation), 360))
-					// Add speed
-					server.Ball.Speed++
-					// Check max speed
-					if server.Ball.Speed > server.Ball.MaxSpeed {
-						server.Ball.Speed = server.Ball.MaxSpeed
-					}
-				} else {
-					// Move normally
-					server.Ball.Y += velocityDown
-				}
-			} else {
-				// Ball is not in range of player - normal move
-				server.Ball.Y += velocityDown
-			}
-		} else {
-			// Player2 did not join - bounce by bottom wall
-			server.Ball.Y += velocityDown
-			if server.Ball.Y >= float64(server.HEIGHT) {
-				server.Ball.Rotation = int(math.Mod(float64(360 - server.Ball.Rotation), 360))
-			}
-		}
-	} else {
-		// Move up
-		velocityUp := math.Abs(velocityY * float64(server.Ball.Speed))
-		if server.Player1 != nil {
-			// Player1 joined - check his boundary
-			if InRangeAround(server.Ball.X, server.Player1.x, float64(server.Player1.width) / 2 + float64(server.Ball.Size), float64(server.Player1.width) / 2 + float64(server.Ball.Size)) {
-				// Ball is in range of player - cautious move
-				if server.Ball.Y - velocityUp - float64(server.Ball.Size) < server.Player1.y {
-					// Stop at players location
-					server.Ball.Y = server.Player1.y + float64(server.Ball.Size)
-					// Bounce
-					server.Ball.Rotation = int(math.Mod(float64(360 - server.Ball.Rotation), 360))
-					// Add speed
-					server.Ball.Speed++
-					// Check max speed
-					if server.Ball.Speed > server.Ball.MaxSpeed {
-						server.Ball.Speed = server.Ball.MaxSpeed
-					}
-				} else {
-					// Move normally
-					server.Ball.Y -= velocityUp
-				}
-			} else {
-				// Ball is not in range of player - normal move
-				server.Ball.Y -= velocityUp
-			}
-		} else {
-			// Player 1 did not join - bounce by top wall
-			server.Ball.Y -= velocityUp
-			if server.Ball.Y <= float64(0) {
-				server.Ball.Rotation = int(math.Mod(float64(360 - server.Ball.Rotation), 360))
-			}
-		}
-	}
+	// Move ball and resolve collisions with walls and paddles
+	StepBall(server)
 
 	if server.Ball.Y <= -1 * float64(server.Ball.Size){
 		// Top player missed ball - add point to player2
 		server.Score2++
-
-		// Reset ball
-		server.Ball.X = float64(server.WIDTH / 2)
-		server.Ball.Y = float64(server.HEIGHT /2)
-		server.Ball.Speed = 3
-
-		randMax := 359
-		randMin := 0
-
-		// Generate new random rotation while we get cardinal directions
-		for server.Ball.Rotation == 0 || server.Ball.Rotation == 90 || server.Ball.Rotation == 180 || server.Ball.Rotation == 270 {
-			server.Ball.Rotation = rand.Intn((randMax - randMin) + randMin)
-		}
+		ResetBall(server, 1)
 	}
 
 	if server.Ball.Y >= float64(server.HEIGHT) + float64(server.Ball.Size) {
 		// Bottom player missed ball - add point to player1
 		server.Score1++
+		ResetBall(server, 2)
+	}
+
+	return nil
+}
+
+// Returns ball to centre of field and aims next serve, conceder is side which lost point (0 for kickoff)
+func ResetBall(server *GameServer, conceder int) {
+	server.Ball.X = float64(server.WIDTH / 2)
+	server.Ball.Y = float64(server.HEIGHT /2)
+	server.Ball.Speed = server.Rules.BallSpeed
 
-		// Reset ball
-		server.Ball.X = float64(server.WIDTH / 2)
-		server.Ball.Y = float64(server.HEIGHT /2)
-		server.Ball.Speed = 3
+	// Random rotation at most 60 degrees from vertical axis so ball does not get stuck between walls
+	random := GameRandom(server)
+	rotation := 30 + random.Intn(121)
 
-		randMax := 359
-		randMin := 0
+	server.serveSide = NextServeSide(server, conceder)
 
-		// Generate new random rotation while we get cardinal directions
-		for server.Ball.Rotation == 0 || server.Ball.Rotation == 90 || server.Ball.Rotation == 180 || server.Ball.Rotation == 270 {
-			server.Ball.Rotation = rand.Intn((randMax - randMin) + randMin)
-		}
+	// Rotation points down to player2, turn it around for player1
+	if server.serveSide == 1 {
+		rotation += 180
 	}
 
-	return nil
-}
\ No newline at end of file
+	SetBallRotation(server.Ball, rotation)
+}
+
+// Returns side (1 top, 2 bottom) which receives next serve
+func NextServeSide(server *GameServer, conceder int) int {
+	// Kickoff goes to random side
+	if server.serveSide == 0 {
+		return 1 + GameRandom(server).Intn(2)
+	}
+
+	if server.Rules.ServeMode == serveAlternate || conceder == 0 {
+		return 3 - server.serveSide
+	}
+
+	return conceder
+}
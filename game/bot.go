@@ -0,0 +1,283 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Decides movement of player controlled by server
+type Controller interface {
+	// Sets movement command of player for current tick
+	Control(game *GameServer, player *Player)
+	// Name of controller shown to other players
+	Name() string
+}
+
+// Skill of bot
+type BotDifficulty struct {
+	// Name of difficulty
+	Name string
+	// Ticks before bot notices what ball does
+	ReactionTicks int
+	// Maximum distance (pixels) between predicted and real ball position
+	PredictionError float64
+	// Share of player speed bot can use (0-1)
+	MaxSpeed float64
+}
+
+// Available bot difficulties
+var botDifficulties = map[string]BotDifficulty{
+	"easy":   {Name: "easy", ReactionTicks: 10, PredictionError: 80, MaxSpeed: 0.5},
+	"normal": {Name: "normal", ReactionTicks: 5, PredictionError: 55, MaxSpeed: 0.8},
+	"hard":   {Name: "hard", ReactionTicks: 2, PredictionError: 30, MaxSpeed: 1},
+}
+
+// Ball as seen by bot at some tick
+type ballObservation struct {
+	position  Vector
+	direction Vector
+	speed     float64
+}
+
+// Controller of bot player
+type BotController struct {
+	difficulty BotDifficulty
+	// Observed ball states, oldest first
+	observations []ballObservation
+	// Error of prediction of current ball approach
+	predictionOffset float64
+	// Ball was approaching bot during last tick
+	approaching bool
+	// Unused part of speed from previous ticks
+	speedCarry float64
+}
+
+// Returns difficulty with given name
+func ParseBotDifficulty(name string) (BotDifficulty, error) {
+	difficulty, exist := botDifficulties[name]
+
+	if !exist {
+		return BotDifficulty{}, fmt.Errorf("unknown bot difficulty %s", name)
+	}
+
+	return difficulty, nil
+}
+
+// Reads optional bot settings from create game message - bot difficulty and seconds to wait for human opponent
+func ParseBotSettings(content map[string]string) (string, time.Duration, error) {
+	botValue, botPresent := content["bot"]
+
+	if !botPresent {
+		return "", 0, nil
+	}
+
+	_, errDifficulty := ParseBotDifficulty(botValue)
+
+	if errDifficulty != nil {
+		return "", 0, errDifficulty
+	}
+
+	botAfterValue, botAfterPresent := content["botAfter"]
+
+	if !botAfterPresent {
+		return botValue, 0, nil
+	}
+
+	botAfter, errParse := strconv.ParseFloat(botAfterValue, 64)
+
+	if errParse != nil || math.IsNaN(botAfter) || botAfter < 0 || botAfter > 600 {
+		return "", 0, errors.New("botAfter must be number between 0 and 600")
+	}
+
+	return botValue, time.Duration(botAfter * float64(time.Second)), nil
+}
+
+// Creates player controlled by bot of given difficulty
+func CreateBot(manager *Manager, difficulty BotDifficulty) *Player {
+	controller := &BotController{difficulty: difficulty}
+
+	player := &Player{
+		client:     nil,
+		ID:         manager.nextPlayerID,
+		userName:   controller.Name(),
+		controller: controller,
+		rating:     initialRating,
+	}
+
+	// Bots are not stored between players, they live only in their game
+	manager.nextPlayerID++
+
+	return player
+}
+
+// Fills free side of game with bot
+func AddBot(manager *Manager, game *GameServer, difficultyName string) (*Player, error) {
+	difficulty, errDifficulty := ParseBotDifficulty(difficultyName)
+
+	if errDifficulty != nil {
+		return nil, errDifficulty
+	}
+
+	if game.Player1 != nil && game.Player2 != nil {
+		return nil, errors.New("game is full")
+	}
+
+	bot := CreateBot(manager, difficulty)
+
+	if game.Player1 == nil {
+		game.Player1 = bot
+	} else {
+		game.Player2 = bot
+	}
+
+	_ = PlacePlayer(game, bot)
+
+	fmt.Printf("game #%d: %s joined\n", game.UID, bot.userName)
+
+	NotifyGameMembers(manager, game, bot, PlayerSide(game, bot), memberJoined)
+
+	return bot, nil
+}
+
+// Seats bots in place of opponents who did not come in time (called only from manager housekeeping)
+func AddWaitingBots(manager *Manager) {
+	for _, game := range manager.GameServers {
+		if game.botDifficulty == "" || game.State != StateWaitingForOpponent {
+			continue
+		}
+
+		// Exactly one player waits for opponent
+		if (game.Player1 == nil) == (game.Player2 == nil) {
+			continue
+		}
+
+		if time.Since(game.StateChanged) >= game.botAfter {
+			_, _ = AddBot(manager, game, game.botDifficulty)
+		}
+	}
+}
+
+// Returns if player is controlled by server
+func IsBot(player *Player) bool {
+	return player != nil && player.controller != nil
+}
+
+// Removes bots from game where no human plays anymore
+func RemoveLonelyBots(game *GameServer) {
+	if IsBot(game.Player1) && (game.Player2 == nil || IsBot(game.Player2)) ||
+		IsBot(game.Player2) && (game.Player1 == nil || IsBot(game.Player1)) {
+		game.Player1 = nil
+		game.Player2 = nil
+	}
+}
+
+// Lets controllers of players decide their movement for current tick
+func ApplyControllers(game *GameServer) {
+	for _, player := range []*Player{game.Player1, game.Player2} {
+		if IsBot(player) {
+			player.controller.Control(game, player)
+		}
+	}
+}
+
+func (bot *BotController) Name() string {
+	return fmt.Sprintf("Bot (%s)", bot.difficulty.Name)
+}
+
+// Moves bot paddle toward predicted position of ball
+func (bot *BotController) Control(game *GameServer, player *Player) {
+	if game.Ball == nil {
+		return
+	}
+
+	// Remember ball, bot reacts to what happened some ticks ago
+	bot.observations = append(bot.observations, ballObservation{
+		position:  Vector{X: game.Ball.X, Y: game.Ball.Y},
+		direction: game.Ball.Direction,
+		speed:     game.Ball.Speed,
+	})
+
+	if len(bot.observations) > bot.difficulty.ReactionTicks+1 {
+		bot.observations = bot.observations[1:]
+	}
+
+	seen := bot.observations[0]
+
+	// Ball is approaching when it moves toward bots side
+	approaching := (player.y < float64(game.HEIGHT/2)) == (seen.direction.Y < 0)
+
+	target := float64(game.WIDTH) / 2
+
+	if approaching && game.State == StatePlaying {
+		// New approach - choose how much bot misjudges it
+		if !bot.approaching {
+			bot.predictionOffset = (GameRandom(game).Float64()*2 - 1) * bot.difficulty.PredictionError
+		}
+
+		target = PredictBallX(game, seen, player.y) + bot.predictionOffset
+	}
+
+	bot.approaching = approaching
+
+	// Use only allowed share of speed
+	bot.speedCarry += bot.difficulty.MaxSpeed
+
+	player.commandInput = true
+	player.moveDirection = 0
+
+	if bot.speedCarry < 1 {
+		return
+	}
+
+	bot.speedCarry -= 1
+
+	distance := target - player.x
+
+	if math.Abs(distance) < float64(game.PLAYER_SPEED)/2 {
+		return
+	}
+
+	if distance < 0 {
+		player.moveDirection = -1
+	} else {
+		player.moveDirection = 1
+	}
+}
+
+// Returns x coordination where ball crosses given y level, bounces from side walls included
+func PredictBallX(game *GameServer, ball ballObservation, y float64) float64 {
+	velocity := ball.direction.Scale(ball.speed)
+
+	if math.Abs(velocity.Y) < physicsEpsilon {
+		return ball.position.X
+	}
+
+	ticks := (y - ball.position.Y) / velocity.Y
+
+	if ticks < 0 {
+		return ball.position.X
+	}
+
+	// Unfold walls - ball moves in field narrowed by its radius
+	radius := float64(game.Ball.Size)
+	span := float64(game.WIDTH) - 2*radius
+
+	if span <= 0 {
+		return float64(game.WIDTH) / 2
+	}
+
+	x := math.Mod(ball.position.X+velocity.X*ticks-radius, 2*span)
+
+	if x < 0 {
+		x += 2 * span
+	}
+
+	if x > span {
+		x = 2*span - x
+	}
+
+	return radius + x
+}
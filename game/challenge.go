@@ -0,0 +1,284 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// How long challenged player has to answer
+const challengeTimeout = 30 * time.Second
+
+// Invitation of one player to game with other player
+type Challenge struct {
+	// Challenge ID
+	ID int
+	// Player who sent challenge, he plays as Player1
+	Challenger *Player
+	// Challenged player, he plays as Player2
+	Target *Player
+	// Rules of game proposed by challenger
+	Rules Rules
+	// Time when challenge can no longer be accepted
+	Expires time.Time
+}
+
+// Removes challenges which were not answered in time and informs players
+func PruneChallenges(manager *Manager) {
+	for challengeID, challenge := range manager.Challenges {
+		if time.Now().Before(challenge.Expires) {
+			continue
+		}
+
+		delete(manager.Challenges, challengeID)
+
+		data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Challenge expired;challenge:expired;challengeID:%d;>", actionChallenge, challengeID))
+		SendToPlayer(manager, challenge.Challenger, data)
+		SendToPlayer(manager, challenge.Target, data)
+	}
+}
+
+// Returns challenge player sent to target, nil if there is none
+func findChallenge(manager *Manager, challenger *Player, target *Player) *Challenge {
+	for _, challenge := range manager.Challenges {
+		if challenge.Challenger.ID == challenger.ID && challenge.Target.ID == target.ID {
+			return challenge
+		}
+	}
+
+	return nil
+}
+
+// Creates challenge of target player and informs him
+func SendChallenge(manager *Manager, challenger *Player, targetName string, rules Rules) (*Challenge, error) {
+	target, errFind := GetPlayerByName(manager, targetName)
+
+	if errFind != nil || !IsAlive(target) {
+		return nil, errors.New("player is not online")
+	}
+
+	if target.ID == challenger.ID {
+		return nil, errors.New("you cannot challenge yourself")
+	}
+
+	if IsBlocked(target, challenger) {
+		return nil, errors.New("player does not accept your challenges")
+	}
+
+	if !isFreeForGame(manager, challenger) {
+		return nil, errors.New("already in another game")
+	}
+
+	if !isFreeForGame(manager, target) {
+		return nil, errors.New("player is in another game")
+	}
+
+	if findChallenge(manager, challenger, target) != nil {
+		return nil, errors.New("player was already challenged")
+	}
+
+	errRules := ValidateRules(rules)
+
+	if errRules != nil {
+		return nil, errRules
+	}
+
+	challenge := &Challenge{
+		ID:         manager.nextChallengeID,
+		Challenger: challenger,
+		Target:     target,
+		Rules:      rules,
+		Expires:    time.Now().Add(challengeTimeout),
+	}
+
+	manager.nextChallengeID++
+	manager.Challenges[challenge.ID] = challenge
+
+	data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:You were challenged;challenge:received;challengeID:%d;opponent:%s;expires:%d;%s>", actionChallenge, challenge.ID, communication.Escape(challenger.userName), int(challengeTimeout.Seconds()), BuildRulesContent(rules)))
+	SendToPlayer(manager, target, data)
+
+	return challenge, nil
+}
+
+// Creates game of accepted challenge with both players seated
+func StartChallenge(manager *Manager, challenge *Challenge) (*GameServer, error) {
+	delete(manager.Challenges, challenge.ID)
+
+	for _, player := range []*Player{challenge.Challenger, challenge.Target} {
+		if manager.Players[player.ID] != player || !IsAlive(player) {
+			return nil, errors.New("opponent is not online")
+		}
+
+		if !isFreeForGame(manager, player) {
+			return nil, errors.New("player is in another game")
+		}
+	}
+
+	game, errCreate := CreateConfiguredGame(manager, challenge.Challenger, challenge.Rules, time.Now().UnixNano())
+
+	if errCreate != nil {
+		return nil, errCreate
+	}
+
+	game.Player1 = challenge.Challenger
+	game.Player2 = challenge.Target
+
+	for _, player := range []*Player{game.Player1, game.Player2} {
+		_, _ = StopSpectating(manager, player)
+		_ = LeaveQueue(manager, player)
+	}
+
+	fmt.Printf("challenge #%d: %s and %s play game #%d\n", challenge.ID, game.Player1.userName, game.Player2.userName, game.UID)
+
+	go GameStart(manager, game)
+
+	return game, nil
+}
+
+// Player challenges other player by name:<username>, challenged player answers with challengeID and accept (default true),
+// challenger can cancel challenge with challengeID and accept:false
+func ChallengeAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("challenge: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("challenge: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - User does not exist;>", message.Rid, actionChallenge))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("challenge: User does not exist")
+	}
+
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - User is not registered;>", message.Rid, actionChallenge))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("challenge: User not registered")
+	}
+
+	PruneChallenges(manager)
+
+	// Player challenges other player
+	targetName, namePresent := message.Content["name"]
+
+	if namePresent {
+		rules, errRules := ParseRules(message.Content)
+
+		if errRules != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - %s;>", message.Rid, actionChallenge, errRules.Error()))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return fmt.Errorf("challenge: %s", errRules.Error())
+		}
+
+		challenge, errChallenge := SendChallenge(manager, player, targetName, rules)
+
+		if errChallenge != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - %s;>", message.Rid, actionChallenge, errChallenge.Error()))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return fmt.Errorf("challenge: %s", errChallenge.Error())
+		}
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Challenge sent;challenge:sent;challengeID:%d;opponent:%s;expires:%d;>", message.Rid, actionChallenge, challenge.ID, communication.Escape(challenge.Target.userName), int(challengeTimeout.Seconds())))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return nil
+	}
+
+	// Player answers challenge
+	challengeIDValue, challengeIDPresent := message.Content["challengeID"]
+
+	if !challengeIDPresent {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - Missing name or challenge ID;>", message.Rid, actionChallenge))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("challenge: missing name or challenge ID")
+	}
+
+	challengeID, errParse := strconv.Atoi(challengeIDValue)
+
+	if errParse != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - Challenge ID needs to be number;>", message.Rid, actionChallenge))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("challenge: Challenge ID is not number")
+	}
+
+	challenge, challengeExist := manager.Challenges[challengeID]
+
+	if !challengeExist || (challenge.Target.ID != player.ID && challenge.Challenger.ID != player.ID) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - Challenge does not exist;>", message.Rid, actionChallenge))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("challenge: challenge does not exist")
+	}
+
+	// Accept is default, accept:false declines or cancels challenge
+	accept := true
+	acceptValue, acceptPresent := message.Content["accept"]
+
+	if acceptPresent {
+		parsed, errParseAccept := strconv.ParseBool(acceptValue)
+
+		if errParseAccept != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - accept must be true or false;>", message.Rid, actionChallenge))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("challenge: accept is not bool")
+		}
+
+		accept = parsed
+	}
+
+	// Challenger takes challenge back
+	if challenge.Challenger.ID == player.ID {
+		if accept {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - Only challenged player can accept;>", message.Rid, actionChallenge))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("challenge: challenger cannot accept")
+		}
+
+		delete(manager.Challenges, challenge.ID)
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Challenge cancelled;challenge:cancelled;challengeID:%d;>", message.Rid, actionChallenge, challenge.ID))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Challenge was cancelled;challenge:cancelled;challengeID:%d;>", actionChallenge, challenge.ID))
+		SendToPlayer(manager, challenge.Target, data)
+		return nil
+	}
+
+	// Challenged player declines
+	if !accept {
+		delete(manager.Challenges, challenge.ID)
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Challenge declined;challenge:declined;challengeID:%d;>", message.Rid, actionChallenge, challenge.ID))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Challenge was declined;challenge:declined;challengeID:%d;>", actionChallenge, challenge.ID))
+		SendToPlayer(manager, challenge.Challenger, data)
+		return nil
+	}
+
+	// Challenged player accepts - start game
+	game, errStart := StartChallenge(manager, challenge)
+
+	if errStart != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Challenge failed - %s;>", message.Rid, actionChallenge, errStart.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Challenge could not start;challenge:failed;challengeID:%d;>", actionChallenge, challenge.ID))
+		SendToPlayer(manager, challenge.Challenger, data)
+		return fmt.Errorf("challenge: %s", errStart.Error())
+	}
+
+	rules := BuildRulesContent(game.Rules)
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Challenge accepted;challenge:accepted;challengeID:%d;GameID:%d;player:2;%s>", message.Rid, actionChallenge, challenge.ID, game.UID, rules))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+	data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Challenge accepted;challenge:accepted;challengeID:%d;GameID:%d;player:1;%s>", actionChallenge, challenge.ID, game.UID, rules))
+	SendToPlayer(manager, challenge.Challenger, data)
+
+	return nil
+}
@@ -0,0 +1,350 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Chat scopes
+const (
+	// Players subscribed to lobby
+	chatLobby = "lobby"
+	// Players and spectators of game
+	chatGame = "game"
+	// Single player by name
+	chatWhisper = "whisper"
+)
+
+// Ways to ignore other player
+const (
+	// Lobby and game messages of player are not delivered
+	ignoreMute = "mute"
+	// Whispers and challenges of player are refused too
+	ignoreBlock = "block"
+)
+
+const (
+	// Key of chat text, its length is limited by codec
+	chatTextKey = "text"
+	// Longest chat text
+	maxChatLength = 200
+	// Messages player can send at once
+	chatBurst = 5
+	// Messages player can send per second in long run
+	chatRate = 1.0
+	// Messages kept for players who join room later
+	chatHistorySize = 20
+)
+
+// Message of chat room
+type ChatEntry struct {
+	FromID int
+	From   string
+	Text   string
+	Sent   time.Time
+}
+
+// Chat settings and state of player
+type ChatState struct {
+	// Messages player can send right now
+	tokens float64
+	// Time when tokens were last refilled
+	refilled time.Time
+	// Players whose lobby and game messages are not delivered (by player ID)
+	muted map[int]bool
+	// Players who cannot whisper or challenge player (by player ID)
+	blocked map[int]bool
+}
+
+// Returns if player can send another message, uses one message of his limit
+func takeChatToken(player *Player) bool {
+	state := &player.chat
+
+	if state.refilled.IsZero() {
+		state.tokens = chatBurst
+	} else {
+		state.tokens += time.Since(state.refilled).Seconds() * chatRate
+	}
+
+	if state.tokens > chatBurst {
+		state.tokens = chatBurst
+	}
+
+	state.refilled = time.Now()
+
+	if state.tokens < 1 {
+		return false
+	}
+
+	state.tokens--
+
+	return true
+}
+
+// Returns if receiver does not want messages of sender in given scope
+func isIgnored(receiver *Player, sender *Player, scope string) bool {
+	if receiver.chat.blocked[sender.ID] {
+		return true
+	}
+
+	return scope != chatWhisper && receiver.chat.muted[sender.ID]
+}
+
+// Returns if receiver refuses whispers and challenges of sender
+func IsBlocked(receiver *Player, sender *Player) bool {
+	return receiver.chat.blocked[sender.ID]
+}
+
+// Appends message to history of room, oldest messages are forgotten
+func appendChatHistory(history []ChatEntry, entry ChatEntry) []ChatEntry {
+	history = append(history, entry)
+
+	if len(history) > chatHistorySize {
+		history = history[len(history)-chatHistorySize:]
+	}
+
+	return history
+}
+
+// Builds chat message delivered to players
+func buildChatMessage(scope string, gameID int, entry ChatEntry, history bool) []byte {
+	return []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;scope:%s;gameID:%d;fromID:%d;from:%s;text:%s;sent:%d;history:%t;>", actionChatMessage, scope, gameID, entry.FromID, communication.Escape(entry.From), communication.Escape(entry.Text), entry.Sent.Unix(), history))
+}
+
+// Sends message to player unless he ignores sender
+func deliverChat(manager *Manager, receiver *Player, sender *Player, scope string, data []byte) {
+	if receiver == nil || IsBot(receiver) || isIgnored(receiver, sender, scope) {
+		return
+	}
+
+	SendToPlayer(manager, receiver, data)
+}
+
+// Sends history of lobby chat to player
+func ReplayLobbyChat(manager *Manager, player *Player) {
+	for _, entry := range manager.lobbyChat {
+		sender, errFind := GetPlayerByID(manager, entry.FromID)
+
+		if errFind == nil && isIgnored(player, sender, chatLobby) {
+			continue
+		}
+
+		SendToPlayer(manager, player, buildChatMessage(chatLobby, 0, entry, true))
+	}
+}
+
+// Sends history of game chat to player who joined game
+func ReplayGameChat(manager *Manager, game *GameServer, player *Player) {
+	for _, entry := range game.chatHistory {
+		sender, errFind := GetPlayerByID(manager, entry.FromID)
+
+		if errFind == nil && isIgnored(player, sender, chatGame) {
+			continue
+		}
+
+		SendToPlayer(manager, player, buildChatMessage(chatGame, game.UID, entry, true))
+	}
+}
+
+// Delivers message of player to chosen scope
+func SendChat(manager *Manager, player *Player, scope string, target string, text string) error {
+	if text == "" {
+		return errors.New("text cannot be empty")
+	}
+
+	if len(text) > maxChatLength {
+		return fmt.Errorf("text can have %d characters at most", maxChatLength)
+	}
+
+	entry := ChatEntry{
+		FromID: player.ID,
+		From:   player.userName,
+		Text:   text,
+		Sent:   time.Now(),
+	}
+
+	switch scope {
+	case chatLobby:
+		if !takeChatToken(player) {
+			return errors.New("too many messages, slow down")
+		}
+
+		manager.lobbyChat = appendChatHistory(manager.lobbyChat, entry)
+		data := buildChatMessage(chatLobby, 0, entry, false)
+
+		for _, receiver := range manager.Players {
+			if receiver.lobbySubscribed {
+				deliverChat(manager, receiver, player, chatLobby, data)
+			}
+		}
+
+	case chatGame:
+		game, errGame := GetPlayersGame(manager, player)
+
+		if errGame != nil {
+			game, errGame = GetSpectatedGame(manager, player)
+		}
+
+		if errGame != nil {
+			return errors.New("you are not in any game")
+		}
+
+		if !takeChatToken(player) {
+			return errors.New("too many messages, slow down")
+		}
+
+		game.chatHistory = appendChatHistory(game.chatHistory, entry)
+		data := buildChatMessage(chatGame, game.UID, entry, false)
+
+		for _, receiver := range []*Player{game.Player1, game.Player2} {
+			deliverChat(manager, receiver, player, chatGame, data)
+		}
+
+		for _, receiver := range GameSpectators(game) {
+			deliverChat(manager, receiver, player, chatGame, data)
+		}
+
+	case chatWhisper:
+		receiver, errFind := GetPlayerByName(manager, target)
+
+		if errFind != nil || receiver.client == nil {
+			return errors.New("player is not online")
+		}
+
+		if receiver.ID == player.ID {
+			return errors.New("you cannot whisper to yourself")
+		}
+
+		if IsBlocked(receiver, player) {
+			return errors.New("player does not accept your messages")
+		}
+
+		if !takeChatToken(player) {
+			return errors.New("too many messages, slow down")
+		}
+
+		deliverChat(manager, receiver, player, chatWhisper, buildChatMessage(chatWhisper, 0, entry, false))
+
+	default:
+		return fmt.Errorf("scope must be %s, %s or %s", chatLobby, chatGame, chatWhisper)
+	}
+
+	return nil
+}
+
+// Player sends chat message, scope:lobby|game|whisper, whisper needs name of receiver
+func ChatAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("chat: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("chat: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Message not sent - User does not exist;>", message.Rid, actionChat))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("chat: User does not exist")
+	}
+
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Message not sent - User is not registered;>", message.Rid, actionChat))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("chat: User not registered")
+	}
+
+	scope, scopePresent := message.Content["scope"]
+
+	if !scopePresent {
+		scope = chatLobby
+	}
+
+	errSend := SendChat(manager, player, scope, message.Content["name"], message.Content[chatTextKey])
+
+	if errSend != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Message not sent - %s;>", message.Rid, actionChat, errSend.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("chat: %s", errSend.Error())
+	}
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Message sent;scope:%s;>", message.Rid, actionChat, scope))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+	return nil
+}
+
+// Player mutes or blocks other player by name, mode:mute|block, enabled:false removes it
+func ChatIgnoreAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("chatIgnore: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("chatIgnore: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil || !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot ignore player - User is not registered;>", message.Rid, actionChatIgnore))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("chatIgnore: User not registered")
+	}
+
+	mode, modePresent := message.Content["mode"]
+
+	if !modePresent {
+		mode = ignoreMute
+	}
+
+	if mode != ignoreMute && mode != ignoreBlock {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot ignore player - mode must be %s or %s;>", message.Rid, actionChatIgnore, ignoreMute, ignoreBlock))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("chatIgnore: unknown mode")
+	}
+
+	enabled := true
+	enabledValue, enabledPresent := message.Content["enabled"]
+
+	if enabledPresent {
+		parsed, errParse := strconv.ParseBool(enabledValue)
+
+		if errParse != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot ignore player - enabled must be true or false;>", message.Rid, actionChatIgnore))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("chatIgnore: enabled is not bool")
+		}
+
+		enabled = parsed
+	}
+
+	other, errFindOther := GetPlayerByName(manager, message.Content["name"])
+
+	if errFindOther != nil || other.ID == player.ID {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot ignore player - player does not exist;>", message.Rid, actionChatIgnore))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("chatIgnore: player does not exist")
+	}
+
+	list := player.chat.muted
+	if mode == ignoreBlock {
+		list = player.chat.blocked
+	}
+
+	if enabled {
+		list[other.ID] = true
+	} else {
+		delete(list, other.ID)
+	}
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Ignore list updated;name:%s;mode:%s;enabled:%t;>", message.Rid, actionChatIgnore, communication.Escape(other.userName), mode, enabled))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+	return nil
+}
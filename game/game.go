@@ -4,28 +4,80 @@ import (
 	"../communication"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 )
 
+const (
+	// How many ticks of movement can single input contain before it is considered impossible
+	maxInputTicksAhead = 4
+	// How many ticks ahead of server can movement command be scheduled
+	maxInputTickLead = 30
+	// Default ball angle from vertical after paddle edge hit in degrees
+	defaultBounceAngle = 60.0
+	// Default share of paddle speed passed to ball
+	defaultSpinFactor = 0.3
+)
+
+// Movement command is scheduled for later tick
+var errInputNotDue = errors.New("input is scheduled for later tick")
+
+// Message forwarded to game loop together with player who sent it
+type GameMessage struct {
+	// Player resolved from connection of sender
+	Player *Player
+	// Message as it was received from client
+	Message *communication.Message
+}
+
 type GameServer struct {
 	// GameServer (Lobby) ID
 	UID int
+	// Name of player who created game
+	Creator string
+	// Information when game was created
+	Created time.Time
 	// Players
 	Player1 *Player
 	Player2 *Player
+	// Players watching game (by player ID), manager changes them while game goroutine sends to them
+	Spectators map[int]*Player
+	spectatorsLock sync.RWMutex
 	// Ball
 	Ball *Ball
 	// Ticks per second
 	Tps int
-	// Flag if game is running
-	Running bool
-	// Flag if game is paused
-	Paused bool
+	// Lifecycle state of game
+	State GameState
+	// Information when game entered current state
+	StateChanged time.Time
+	// Ticks left until ball is served
+	countdown int
+	// Side which received last serve (1 top, 2 bottom, 0 before kickoff)
+	serveSide int
+	// Side which requested current pause
+	pausedBy int
+	// Used pauses and paused ticks, indexed by side
+	pauses     [3]int
+	pauseTicks [3]int
+	// Ticks of current pause
+	currentPauseTicks int
+	// IDs of players whose lost connection was announced, indexed by side
+	awayPlayers [3]int
+	// IDs of players who confirmed ready before ready check started, indexed by side
+	earlyReady [3]int
+	// Side which won finished game and reason of game end
+	winner    int
+	endReason string
 	// Information when game loop started
 	Start time.Time
 	// Max tick duration
 	TickDuration int64
+	// Number of ticks simulated since game start
+	Tick int64
 
 	// ##################################
 	// GAME SPECIFIC VARIABLE
@@ -36,18 +88,43 @@ type GameServer struct {
 	PLAYER_SIZE_HEIGHT int 	// SIZE ON Y AXIS: 3
 	PLAYER_SPEED int		// PLAYER SPEED PER TICK: 8
 	PLAYER_GAP int			// PLAYER GAP FROM BORDERS: 10
+	BOUNCE_ANGLE float64	// BALL ANGLE FROM VERTICAL AFTER PADDLE EDGE HIT IN DEGREES: 60
+	SPIN_FACTOR float64		// SHARE OF PADDLE SPEED PASSED TO BALL: 0.3
+
+	// Rules chosen by creator of game
+	Rules Rules
 
 	Score1 int				// Score for player1
 	Score2 int				// Score for player 2
 
 	// ##################################
 	// Server messages
-	Messages []*communication.Message
+	Messages []*GameMessage
+	// Recorded messages waiting for processing, commands for later ticks stay here
+	pendingInputs []*GameMessage
 	sentMessages int64
+
+	// ##################################
+	// Series game belongs to, nil for single game
+	Series *Series
+	// Private game settings
+	Privacy Privacy
+	// Last chat messages of game
+	chatHistory []ChatEntry
+	// Difficulty of bot which fills missing opponent (empty for no bot) and how long to wait for human first
+	botDifficulty string
+	botAfter      time.Duration
+
+	// ##################################
+	// Randomness - match can be reproduced from seed and players input
+	Seed int64
+	random *rand.Rand
+	// Replay recording, nil if game is not recorded
+	recorder *Recorder
 }
 
 
-func GameUpdatePlayer(manager *Manager, server *GameServer, message *communication.Message) error {
+func GameUpdatePlayer(manager *Manager, server *GameServer, player *Player, message *communication.Message) error {
 	if manager == nil {
 		return errors.New("unable parse players input: manager cannot be null")
 	}
@@ -65,18 +142,6 @@ func GameUpdatePlayer(manager *Manager, server *GameServer, message *communicati
 		return errors.New("unable to pause players input: wrong message type")
 	}
 
-	playerIDValue, playerIDPresent := message.Content["playerID"]
-
-	if !playerIDPresent {
-		return errors.New("unable to process players input: player ID is missing")
-	}
-
-	playerIDValueInt, errConvID := strconv.Atoi(playerIDValue)
-
-	if errConvID != nil {
-		return errors.New("unable to process players input: player ID is not number")
-	}
-
 	playerXValue, playerXPresent := message.Content["x"]
 
 	if !playerXPresent {
@@ -103,154 +168,474 @@ func GameUpdatePlayer(manager *Manager, server *GameServer, message *communicati
 	}
 
 
-	if server.Player1 != nil && server.Player1.ID == playerIDValueInt {
-		server.Player1.x = playerXValueFloat
+	// Player could leave game before message was processed
+	if player == nil || PlayerSide(server, player) == 0 {
+		return errors.New("unable to process players input: player is not in this game")
 	}
 
-	if server.Player2 != nil && server.Player2.ID == playerIDValueInt {
-		server.Player2.x = playerXValueFloat
+	// Pause or resume game when paused flag changes
+	pausedValue, pausedPresent := message.Content["paused"]
+
+	if pausedPresent {
+		paused, errConvPaused := strconv.ParseBool(pausedValue)
+
+		if errConvPaused == nil {
+			UpdatePauseFlag(manager, server, player, paused)
+		}
+	}
+
+	// Player already switched to movement commands
+	if player.commandInput {
+		return errors.New("unable to process players input: player uses movement commands")
+	}
+
+	errMove := MovePlayer(server, player, playerXValueFloat)
+
+	if errMove != nil {
+		if player.client != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Movement rejected - %s;>", message.Rid, actionPlayerPositionUpdate, errMove.Error()))
+			_ = communication.SendID(manager.CommunicationServer, data, player.client.UID)
+		}
+		return errMove
 	}
 
 	return nil
 }
 
-func GameStart(manager *Manager, game *GameServer) {
+// Processes players movement command
+func GamePlayerInput(manager *Manager, server *GameServer, player *Player, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("unable to process players command: manager cannot be null")
+	}
 
-	// Initialize ball
+	if server == nil {
+		return errors.New("unable to process players command: game server cannot be null")
+	}
+
+	if message == nil {
+		return errors.New("unable to process players command: message cannot be null")
+	}
+
+	// Check message type
+	if message.Msg != actionPlayerInput {
+		return errors.New("unable to process players command: wrong message type")
+	}
+
+	seqValue, seqPresent := message.Content["seq"]
+
+	if !seqPresent {
+		return errors.New("unable to process players command: seq is missing")
+	}
+
+	seq, errConvSeq := strconv.Atoi(seqValue)
+
+	if errConvSeq != nil {
+		return errors.New("unable to process players command: seq is not number")
+	}
+
+	tickValue, tickPresent := message.Content["tick"]
+
+	if !tickPresent {
+		return errors.New("unable to process players command: tick is missing")
+	}
+
+	tick, errConvTick := strconv.ParseInt(tickValue, 10, 64)
+
+	if errConvTick != nil {
+		return errors.New("unable to process players command: tick is not number")
+	}
+
+	moveValue, movePresent := message.Content["move"]
+
+	if !movePresent {
+		return errors.New("unable to process players command: move is missing")
+	}
+
+	direction := 0
+	switch moveValue {
+	case "left":
+		direction = -1
+	case "right":
+		direction = 1
+	case "stop":
+		direction = 0
+	default:
+		return errors.New("unable to process players command: unknown move")
+	}
+
+	// Player could leave game before message was processed
+	if player == nil || PlayerSide(server, player) == 0 {
+		return errors.New("unable to process players command: player is not in this game")
+	}
+
+	// Commands which were already processed or arrived out of order are dropped
+	if seq <= player.lastInputSeq {
+		return errors.New("unable to process players command: command is outdated")
+	}
+
+	// Reject commands scheduled too far in future
+	if tick > server.Tick+maxInputTickLead {
+		if player.client != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Command rejected - tick is too far ahead;seq:%d;>", message.Rid, actionPlayerInput, seq))
+			_ = communication.SendID(manager.CommunicationServer, data, player.client.UID)
+		}
+		return errors.New("unable to process players command: tick is too far ahead")
+	}
+
+	// Keep command until its tick comes
+	if tick > server.Tick {
+		return errInputNotDue
+	}
+
+	player.commandInput = true
+	player.moveDirection = direction
+	player.lastInputSeq = seq
+
+	return nil
+}
+
+// Moves players controlled by movement commands
+func ApplyPlayerCommands(server *GameServer) {
+	for _, player := range []*Player{server.Player1, server.Player2} {
+		if player == nil || !player.commandInput || player.moveDirection == 0 {
+			continue
+		}
+
+		target := ClampPlayerX(server, player, player.x+float64(player.moveDirection*server.PLAYER_SPEED))
+		_ = MovePlayer(server, player, target)
+	}
+}
+
+// Places player to the middle of his side of game field
+func PlacePlayer(game *GameServer, player *Player) error {
+	if game == nil {
+		return errors.New("unable to place player: game cannot be null")
+	}
+
+	if player == nil {
+		return errors.New("unable to place player: player cannot be null")
+	}
+
+	player.x = float64(game.WIDTH / 2)
+	player.width = float64(game.PLAYER_SIZE_WIDTH)
+	player.height = float64(game.PLAYER_SIZE_HEIGHT)
+	player.moveBudget = 0
+	player.tickStartX = player.x
+	player.commandInput = false
+	player.moveDirection = 0
+	player.lastInputSeq = 0
+
+	if game.Player1 == player {
+		player.y = float64(0 + game.PLAYER_GAP)
+	} else {
+		player.y = float64(game.HEIGHT - game.PLAYER_GAP)
+	}
+
+	return nil
+}
+
+// Returns x coordination limited to game field for given player
+func ClampPlayerX(server *GameServer, player *Player, x float64) float64 {
+	minX := player.width / 2
+	maxX := float64(server.WIDTH) - player.width/2
+
+	if x < minX {
+		return minX
+	}
+
+	if x > maxX {
+		return maxX
+	}
+
+	return x
+}
+
+// Gives players full movement distance for the next tick
+func ResetMoveBudget(server *GameServer) {
+	if server.Player1 != nil {
+		server.Player1.moveBudget = float64(server.PLAYER_SPEED)
+		server.Player1.tickStartX = server.Player1.x
+	}
+
+	if server.Player2 != nil {
+		server.Player2.moveBudget = float64(server.PLAYER_SPEED)
+		server.Player2.tickStartX = server.Player2.x
+	}
+}
+
+// Returns distance player moved during current tick (negative = left)
+func PlayerVelocity(player *Player) float64 {
+	if player == nil {
+		return 0
+	}
+
+	return player.x - player.tickStartX
+}
+
+// Moves player towards requested x coordination - server decides where player really ends
+func MovePlayer(server *GameServer, player *Player, x float64) error {
+	if server == nil {
+		return errors.New("game server cannot be null")
+	}
+
+	if player == nil {
+		return errors.New("player cannot be null")
+	}
+
+	// Reject coordinations which cannot be reached by any client
+	if math.IsNaN(x) || math.IsInf(x, 0) || x < 0 || x > float64(server.WIDTH) {
+		player.invalidInputs++
+		fmt.Printf("game #%d: player #%d sent position outside of field (x: %.2f, rejected inputs: %d)\n", server.UID, player.ID, x, player.invalidInputs)
+		return errors.New("position outside of field")
+	}
+
+	displacement := ClampPlayerX(server, player, x) - player.x
+
+	// Reject teleports - client should never be more than few ticks ahead of server
+	if math.Abs(displacement) > float64(server.PLAYER_SPEED*maxInputTicksAhead) {
+		player.invalidInputs++
+		fmt.Printf("game #%d: player #%d moved too fast (distance: %.2f, rejected inputs: %d)\n", server.UID, player.ID, displacement, player.invalidInputs)
+		return errors.New("moved too fast")
+	}
+
+	// Limit displacement to distance player can still move in this tick
+	if displacement > player.moveBudget {
+		displacement = player.moveBudget
+	}
+
+	if displacement < -player.moveBudget {
+		displacement = -player.moveBudget
+	}
+
+	player.x += displacement
+	player.moveBudget -= math.Abs(displacement)
+
+	return nil
+}
+
+// Prepares ball for new game
+func InitializeBall(game *GameServer) {
 	ball := Ball{
 		X:        float64(game.WIDTH / 2),
 		Y:        float64(game.HEIGHT / 2),
-		Rotation: 45,
-		Speed:    3,
-		MaxSpeed: 15,
+		Speed:    game.Rules.BallSpeed,
+		MaxSpeed: game.Rules.BallMaxSpeed,
 		Size: 10,
 	}
 
-
-	// Initialize ball
 	game.Ball = &ball
 
-	// Initialize players position
+	// Aim kickoff, ball waits in centre until countdown ends
+	ResetBall(game, 0)
+}
+
+// Simulates single game tick - processes players input and moves ball
+func GameTick(manager *Manager, game *GameServer) {
+	// Record changes which happened between ticks
+	RecordTickStart(game)
+
+	// Players can move only limited distance per tick
+	ResetMoveBudget(game)
+
+	// Record newly arrived messages, commands waiting for later tick are recorded only once
+	for len(game.Messages) > 0 {
+		message := game.Messages[0]
+		game.Messages = game.Messages[1:]
+
+		RecordInput(game, message)
+		game.pendingInputs = append(game.pendingInputs, message)
+	}
+
+	// Process messages from players, update their position, pause status
+	pending := game.pendingInputs
+	game.pendingInputs = make([]*GameMessage, 0)
+
+	for _, message := range pending {
+		if message.Message.Msg == actionPlayerPositionUpdate {
+			_ = GameUpdatePlayer(manager, game, message.Player, message.Message)
+		}
+
+		if message.Message.Msg == actionPlayerInput {
+			// Commands for future ticks wait in queue
+			if GamePlayerInput(manager, game, message.Player, message.Message) == errInputNotDue {
+				game.pendingInputs = append(game.pendingInputs, message)
+			}
+		}
+
+		if message.Message.Msg == actionPause {
+			_ = GamePauseRequest(manager, game, message.Player, message.Message)
+		}
+
+		if message.Message.Msg == actionReady {
+			_ = GameReadyRequest(manager, game, message.Player, message.Message)
+		}
+	}
+
+	// Let bots decide their movement
+	ApplyControllers(game)
+
+	// Move players using movement commands
+	ApplyPlayerCommands(game)
+
+	// Enforce correct y level
 	if game.Player1 != nil {
 		game.Player1.y = float64(0 + game.PLAYER_GAP)
-		game.Player1.x = float64(game.WIDTH / 2)
-		game.Player1.width = 80
 	}
 
 	if game.Player2 != nil {
 		game.Player2.y = float64(game.HEIGHT - game.PLAYER_GAP)
-		game.Player2.x = float64(game.WIDTH / 2)
-		game.Player2.width = 80
 	}
 
+	// Serve ball after countdown, end pauses which ran out of time
+	CountdownTick(manager, game)
+	PauseTick(manager, game)
+
+	// Update coordinations of ball
+	if game.State == StatePlaying {
+		points := game.Score1 + game.Score2
+
+		_ = UpdateBall(game)
+
+		// Give players time before next serve
+		if game.Score1+game.Score2 != points && GameWinner(game) == 0 {
+			_ = SetGameState(manager, game, StateCountdown, "point scored")
+		}
+	}
+
+	// Record state checksum
+	RecordTickEnd(game)
+}
+
+func GameStart(manager *Manager, game *GameServer) {
+	// Initialize ball
+	InitializeBall(game)
+
+	// Initialize players position
+	_ = PlacePlayer(game, game.Player1)
+	_ = PlacePlayer(game, game.Player2)
+
 	// Setup game tick
-	game.Paused = false
 	game.Start = time.Now()
 	game.TickDuration = int64(1000 / game.Tps)
 
+	// Start recording if server keeps replays
+	if manager.ReplayDirectory != "" {
+		errRecord := StartRecording(game, manager.ReplayDirectory)
+
+		if errRecord != nil {
+			fmt.Printf("game #%d: recording not started: %s\n", game.UID, errRecord.Error())
+		}
+	}
+
 	nextGameTickTime := time.Since(game.Start).Milliseconds()
 
 	// Start game loop
-	for game.Running {
-		// If both players are not null and okay with keepalive unpause game
-		if game.Player1 != nil && game.Player2 != nil && IsAlive(game.Player1) && IsAlive(game.Player2) {
-			if game.Paused {
-				fmt.Printf("game #%d unpaused\n", game.UID)
-				game.Paused = false
-			}
-		} else {
-			if !game.Paused {
-				fmt.Printf("game #%d paused\n", game.UID)
-				game.Paused = true
-			}
-		}
+	for IsGameRunning(game) {
+		// Move game between states by presence and keepalive of players
+		UpdateGameState(manager, game)
 
 		// If enough time passed from last tick we can do next tick
 		for time.Since(game.Start).Milliseconds() >  nextGameTickTime {
-			// Process messages from players, update their position, pause status
-			for len(game.Messages) > 0 {
-				message := game.Messages[0]
-				game.Messages = game.Messages[1:]
-
-				if message.Msg == 3000 {
-					_ = GameUpdatePlayer(manager, game, message)
-				}
-			}
+			// Simulate tick
+			GameTick(manager, game)
 
-			// Enforce correct y level
-			if game.Player1 != nil {
-				game.Player1.y = float64(0 + game.PLAYER_GAP)
-			}
+			// Send current state of game to both players and spectators
+			gameStateMessage, errGameState := BuildGameStateMessage(game)
 
-			if game.Player2 != nil {
-				game.Player2.y = float64(game.HEIGHT - game.PLAYER_GAP)
+			if errGameState == nil {
+				SendToGame(manager, game, []byte(gameStateMessage))
 			}
 
-			// Update coordinations of ball
-			if !game.Paused {
-				_ = UpdateBall(game)
+			// Stop game when somebody reached score limit
+			winner := GameWinner(game)
+			if winner != 0 {
+				_ = EndGame(manager, game, winner, endReasonScore)
 			}
 
-			// Send current state of game to both players
-			gameStateMessage, errGameState := BuildGameStateMessage(game)
+			// Determine next game tick time
+			nextGameTickTime += game.TickDuration
+			game.Tick++
 
-			// To player 1
-			if errGameState == nil && game.Player1 != nil && game.Player1.client != nil {
-				_ = communication.SendID(manager.CommunicationServer, []byte(gameStateMessage), game.Player1.client.UID)
-			}
-			// To player 2
-			if errGameState == nil && game.Player2 != nil && game.Player2.client != nil {
-				_ = communication.SendID(manager.CommunicationServer, []byte(gameStateMessage), game.Player2.client.UID)
+			// No more ticks after game ended, replay ends before tick which was not simulated
+			if !IsGameRunning(game) {
+				break
 			}
+		}
 
-			// Build game end message
-			gameEndMessage, endErr := BuildGameEndMessage(game)
-			if endErr == nil {
-				if game.Player1 != nil {
-					_ = communication.SendID(manager.CommunicationServer, []byte(gameEndMessage), game.Player1.client.UID)
-				}
+	}
 
-				if game.Player2 != nil {
-					_ = communication.SendID(manager.CommunicationServer, []byte(gameEndMessage), game.Player2.client.UID)
-				}
+	// Finish replay file
+	StopRecording(game)
 
-				// Stop game
-				game.Running = false
-			}
+	// Rest of cleanup belongs to manager goroutine
+	manager.finishedGames <- game
+}
 
+// Removes game whose loop ended and plans what follows it, runs on manager goroutine
+func FinishGame(manager *Manager, game *GameServer) {
+	delete(manager.GameServers, game.UID)
 
+	// Series, ratings and accounts are shared with matchmaking, so result is stored here
+	if game.State == StateFinished {
+		// Count game to series before players are informed
+		if game.Series != nil {
+			RecordSeriesGame(game.Series, game, game.winner)
+		}
 
-			// Determine next game tick time
-			nextGameTickTime += game.TickDuration
+		gameEndMessage, errEnd := BuildGameEndMessage(game, game.winner, game.endReason)
+
+		if errEnd == nil {
+			SendToGame(manager, game, []byte(gameEndMessage))
 		}
 
+		RecordResult(manager, game, game.winner, game.endReason)
 	}
 
-	// After end of game send message to players game was completed and delete game
-	delete(manager.GameServers, game.UID)
+	// Players can agree on playing again once finished series is over
+	if game.State == StateFinished && (game.Series == nil || IsSeriesOver(game.Series)) {
+		OpenRematch(manager, game)
+	}
+
+	// Plan next game of series
+	ContinueSeries(manager, game)
 }
 
 // Builds game end message
-func BuildGameEndMessage(game *GameServer) (string, error) {
+func BuildGameEndMessage(game *GameServer, winner int, reason string) (string, error) {
 	if game == nil {
 		return "", errors.New("cannot build game end message: game cannot be null")
 	}
 
-	if game.Score1 < 10 && game.Score2 < 10 {
-		return "", errors.New("not enough score")
+	if winner != 1 && winner != 2 {
+		return "", errors.New("cannot build game end message: winner must be 1 or 2")
 	}
 
 	// Start message with message header
 	msg := fmt.Sprintf("<id:%d;rid:0;type:3100;|status:ok;", game.sentMessages)
 
-	if game.Score1 >= 10 {
-		msg += "msg:Player1 won!;>"
-	}
+	msg += fmt.Sprintf("msg:Player%d won!;", winner)
+	msg += fmt.Sprintf("winner:%d;reason:%s;score1:%d;score2:%d;", winner, reason, game.Score1, game.Score2)
+
+	// Add series progress
+	if game.Series != nil {
+		seriesWinner := 0
+		if game.Series.Winner != 0 {
+			seriesWinner = winner
+		}
+
+		msg += BuildSeriesContent(game)
+		msg += fmt.Sprintf("seriesWinner:%d;", seriesWinner)
 
-	if game.Score2 >= 10 {
-		msg += "msg:Player2 won!;>"
+		if !IsSeriesOver(game.Series) {
+			msg += fmt.Sprintf("nextGame:%d;", int(seriesBreak.Seconds()))
+		}
 	}
 
+	// Add message end
+	msg += ">"
+
+	game.sentMessages++
+
 	return msg, nil
 }
 
@@ -297,7 +682,7 @@ func BuildGameStateMessage(game *GameServer) (string, error) {
 	if game.Ball != nil {
 		ballX = int(game.Ball.X)
 		ballY = int(game.Ball.Y)
-		ballSpeed = game.Ball.Speed
+		ballSpeed = int(math.Round(game.Ball.Speed))
 		ballRotation = game.Ball.Rotation
 	}
 
@@ -306,8 +691,43 @@ func BuildGameStateMessage(game *GameServer) (string, error) {
 	msg += fmt.Sprintf("ballspeed:%d;", ballSpeed)
 	msg += fmt.Sprintf("ballrotation:%d;", ballRotation)
 
-	// Add information - is game paused
-	msg += fmt.Sprintf("paused:%t;", game.Paused)
+	// Add information - is game paused and its state
+	msg += fmt.Sprintf("paused:%t;", IsGamePaused(game))
+	msg += fmt.Sprintf("state:%s;", game.State)
+
+	// Add ticks left until serve
+	countdown := 0
+	if game.State == StateCountdown {
+		countdown = game.countdown
+	}
+
+	msg += fmt.Sprintf("countdown:%d;", countdown)
+
+	// Add side which paused game
+	pausedBy := 0
+	if game.State == StatePausedRequested {
+		pausedBy = game.pausedBy
+	}
+
+	msg += fmt.Sprintf("pausedBy:%d;", pausedBy)
+
+	// Add series progress
+	msg += BuildSeriesContent(game)
+
+	// Add tick and last processed movement commands for client prediction
+	player1seq := 0
+	if game.Player1 != nil {
+		player1seq = game.Player1.lastInputSeq
+	}
+
+	player2seq := 0
+	if game.Player2 != nil {
+		player2seq = game.Player2.lastInputSeq
+	}
+
+	msg += fmt.Sprintf("tick:%d;", game.Tick)
+	msg += fmt.Sprintf("player1seq:%d;", player1seq)
+	msg += fmt.Sprintf("player2seq:%d;", player2seq)
 
 	// Add message end
 	msg += ">"
@@ -335,7 +755,7 @@ func RemoveEmptyGame(manager *Manager, game *GameServer) error {
 	}
 
 	// Stop game
-	game.Running = false
+	_ = SetGameState(manager, game, StateAborted, "all players left")
 
 	// Remove game from games list
 	delete(manager.GameServers, game.UID)
@@ -344,8 +764,13 @@ func RemoveEmptyGame(manager *Manager, game *GameServer) error {
 	return nil
 }
 
-// Creates new game server and stores it in server manager
+// Creates new game server with default rules and random seed and stores it in server manager
 func CreateGame(manager *Manager, creator *Player) (*GameServer,error) {
+	return CreateConfiguredGame(manager, creator, DefaultRules(), time.Now().UnixNano())
+}
+
+// Creates new game server with given rules whose randomness is given by seed and stores it in server manager
+func CreateConfiguredGame(manager *Manager, creator *Player, rules Rules, seed int64) (*GameServer,error) {
 	if manager == nil {
 		return nil, errors.New("server manager cannot be nil")
 	}
@@ -354,70 +779,199 @@ func CreateGame(manager *Manager, creator *Player) (*GameServer,error) {
 		return nil, errors.New("could not ")
 	}
 
+	errRules := ValidateRules(rules)
+
+	if errRules != nil {
+		return nil, errRules
+	}
+
 	/*
-		WIDTH int				// WIDTH OF GAME: 375
-		HEIGHT int				// HEIGHT OF GAME: 600
-		PLAYER_SIZE_WIDTH int   // SIZE ON X AXIS: 80
-		PLAYER_SIZE_HEIGHT int 	// SIZE ON Y AXIS: 3
 		PLAYER_SPEED int		// PLAYER SPEED PER TICK: 8
 		PLAYER_GAP int			// PLAYER GAP FROM BORDERS: 10
+		Rest of game constants is given by rules
 	 */
 	
 	newGame := GameServer{
 		UID:     manager.nextGameID,
+		Creator: creator.userName,
+		Created: time.Now(),
 		Player1: creator,
 		Player2: nil,
-		Tps:     30,
-		Running: true,
-		Paused: true,
+		Spectators: make(map[int]*Player),
+		State: StateWaitingForOpponent,
+		StateChanged: time.Now(),
 		// Constants
-		WIDTH: 375,
-		HEIGHT: 600,
-		PLAYER_SIZE_WIDTH: 80,
-		PLAYER_SIZE_HEIGHT: 3,
 		PLAYER_SPEED: 8,
 		PLAYER_GAP: 8,
 		// Score
 		Score1: 0,
 		Score2: 0,
 		// Game messages
-		Messages: make([]*communication.Message, 0, 10),
+		Messages: make([]*GameMessage, 0, 10),
 		sentMessages: 0,
+		// Randomness
+		Seed: seed,
+		random: rand.New(rand.NewSource(seed)),
 	}
 
+	// Set rule dependent constants
+	ApplyRules(&newGame, rules)
+
 	// Increment next game ID
 	manager.nextGameID++
 
+	fmt.Printf("game #%d created with seed %d (rules: %s)\n", newGame.UID, newGame.Seed, rules.Preset)
+
 	errAdd := ManagerAddGameServer(manager, &newGame)
 	return &newGame,errAdd
 }
 
+// Returns random source of game, all randomness in game has to be drawn from it
+func GameRandom(game *GameServer) *rand.Rand {
+	if game.random == nil {
+		game.random = rand.New(rand.NewSource(game.Seed))
+	}
+
+	return game.random
+}
+
+// Reads optional seed value from create game message
+func ParseSeed(content map[string]string) (int64, bool, error) {
+	seedValue, seedPresent := content["seed"]
+
+	if !seedPresent {
+		return 0, false, nil
+	}
+
+	seed, errParse := strconv.ParseInt(seedValue, 10, 64)
+
+	if errParse != nil {
+		return 0, false, errors.New("seed must be number")
+	}
+
+	return seed, true, nil
+}
+
+// Sends data to both players and all spectators of game
+func SendToGame(manager *Manager, game *GameServer, data []byte) {
+	if game.Player1 != nil && game.Player1.client != nil {
+		_ = communication.SendID(manager.CommunicationServer, data, game.Player1.client.UID)
+	}
+
+	if game.Player2 != nil && game.Player2.client != nil {
+		_ = communication.SendID(manager.CommunicationServer, data, game.Player2.client.UID)
+	}
+
+	for _, spectator := range GameSpectators(game) {
+		if spectator.client != nil {
+			_ = communication.SendID(manager.CommunicationServer, data, spectator.client.UID)
+		}
+	}
+}
+
+// Returns copy of spectators of game which can be used while spectators change
+func GameSpectators(game *GameServer) []*Player {
+	game.spectatorsLock.RLock()
+	defer game.spectatorsLock.RUnlock()
+
+	spectators := make([]*Player, 0, len(game.Spectators))
+
+	for _, spectator := range game.Spectators {
+		spectators = append(spectators, spectator)
+	}
+
+	return spectators
+}
+
+// Returns if player with given ID watches game
+func IsSpectating(game *GameServer, playerID int) bool {
+	game.spectatorsLock.RLock()
+	defer game.spectatorsLock.RUnlock()
+
+	_, spectating := game.Spectators[playerID]
+
+	return spectating
+}
+
+// Attaches player to game as spectator
+func AddSpectator(game *GameServer, player *Player) error {
+	if game == nil {
+		return errors.New("unable to add spectator: game cannot be null")
+	}
+
+	if player == nil {
+		return errors.New("unable to add spectator: player cannot be null")
+	}
+
+	if (game.Player1 != nil && game.Player1.ID == player.ID) || (game.Player2 != nil && game.Player2.ID == player.ID) {
+		return errors.New("unable to add spectator: player plays this game")
+	}
+
+	game.spectatorsLock.Lock()
+	game.Spectators[player.ID] = player
+	game.spectatorsLock.Unlock()
+
+	fmt.Printf("game #%d: player #%d is spectating\n", game.UID, player.ID)
+
+	return nil
+}
+
+// Stops player from spectating any game, returns game player was watching
+func StopSpectating(manager *Manager, player *Player) (*GameServer, error) {
+	game, errFind := GetSpectatedGame(manager, player)
+
+	if errFind != nil {
+		return nil, errFind
+	}
+
+	game.spectatorsLock.Lock()
+	delete(game.Spectators, player.ID)
+	game.spectatorsLock.Unlock()
+
+	fmt.Printf("game #%d: player #%d stopped spectating\n", game.UID, player.ID)
+
+	return game, nil
+}
+
+// Returns game watched by player
+func GetSpectatedGame(manager *Manager, player *Player) (*GameServer, error) {
+	if manager == nil {
+		return nil, errors.New("manager cannot be NULL")
+	}
+
+	if player == nil {
+		return nil, errors.New("player cannot be NULL")
+	}
+
+	for _, game := range manager.GameServers {
+		if IsSpectating(game, player.ID) {
+			return game, nil
+		}
+	}
+
+	return nil, errors.New("player is not spectating any game")
+}
+
 func GetGameByPlayerID(manager *Manager, playerID int) (*GameServer, error) {
 	if manager == nil {
 		return nil, errors.New("manager cannot be NULL")
 	}
 
 	for _, game := range manager.GameServers {
-		fmt.Printf("reconnect find %d VS: \n", playerID)
-		
-		if game.Player1 != nil {
-			fmt.Printf("reconnect player1: %d\n", game.Player1.ID)
-			if game.Player1.ID == playerID {
-				return game, nil			
-			}	
+		if game.Player1 != nil && game.Player1.ID == playerID {
+			return game, nil
+		}
+
+		if game.Player2 != nil && game.Player2.ID == playerID {
+			return game, nil
 		}
 
-		if game.Player2 != nil {
-			fmt.Printf("reconnect player2: %d\n", game.Player2.ID)
-			if game.Player2.ID == playerID {
-				return game, nil				
-			}		
-		} 		
+		if IsSpectating(game, playerID) {
+			return game, nil
+		}
 	}
 
-	fmt.Println("reconnect game was not found")
 	return nil, errors.New("game was not found")
-
 }
 
 func GetGameByID(manager *Manager, gameID int) (*GameServer, error) {
@@ -0,0 +1,304 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Game list filters
+const (
+	// Games with free place
+	filterJoinable = "joinable"
+	// Games where both players play
+	filterInProgress = "inprogress"
+	// Every game
+	filterAll = "all"
+)
+
+// Lobby events
+const (
+	lobbyGameCreated = "created"
+	lobbyGameUpdated = "updated"
+	lobbyGameRemoved = "removed"
+)
+
+// Game member events
+const (
+	memberJoined      = "joined"
+	memberLeft        = "left"
+	memberReconnected = "reconnected"
+)
+
+const (
+	// Games on single page of list when client does not ask for other count
+	defaultPageSize = 20
+	// Most games on single page of list
+	maxPageSize = 50
+)
+
+// Options of game list requested by client
+type GameListQuery struct {
+	Filter   string
+	Preset   string
+	Page     int
+	PageSize int
+}
+
+// Reads game list filters and paging from list games message
+func ParseGameListQuery(content map[string]string) (GameListQuery, error) {
+	query := GameListQuery{
+		Filter:   filterJoinable,
+		Page:     1,
+		PageSize: defaultPageSize,
+	}
+
+	filterValue, filterPresent := content["filter"]
+
+	if filterPresent {
+		if filterValue != filterJoinable && filterValue != filterInProgress && filterValue != filterAll {
+			return GameListQuery{}, fmt.Errorf("filter must be %s, %s or %s", filterJoinable, filterInProgress, filterAll)
+		}
+
+		query.Filter = filterValue
+	}
+
+	presetValue, presetPresent := content["preset"]
+
+	if presetPresent {
+		_, presetExist := rulePresets[presetValue]
+
+		if !presetExist && presetValue != customPreset {
+			return GameListQuery{}, fmt.Errorf("unknown preset %s", presetValue)
+		}
+
+		query.Preset = presetValue
+	}
+
+	pageValue, pagePresent := content["page"]
+
+	if pagePresent {
+		page, errParse := strconv.Atoi(pageValue)
+
+		if errParse != nil || page < 1 {
+			return GameListQuery{}, errors.New("page must be positive number")
+		}
+
+		query.Page = page
+	}
+
+	pageSizeValue, pageSizePresent := content["pageSize"]
+
+	if pageSizePresent {
+		pageSize, errParse := strconv.Atoi(pageSizeValue)
+
+		if errParse != nil || pageSize < 1 || pageSize > maxPageSize {
+			return GameListQuery{}, fmt.Errorf("pageSize must be between 1 and %d", maxPageSize)
+		}
+
+		query.PageSize = pageSize
+	}
+
+	return query, nil
+}
+
+// Returns if game passes filters of query
+func matchesQuery(game *GameServer, query GameListQuery) bool {
+	if !IsGameRunning(game) || game.Privacy.Private {
+		return false
+	}
+
+	if query.Preset != "" && game.Rules.Preset != query.Preset {
+		return false
+	}
+
+	switch query.Filter {
+	case filterJoinable:
+		return game.Player1 == nil || game.Player2 == nil
+	case filterInProgress:
+		return game.Player1 != nil && game.Player2 != nil
+	}
+
+	return true
+}
+
+// Returns games passing filters of query ordered by game ID
+func FilterGames(manager *Manager, query GameListQuery) []*GameServer {
+	games := make([]*GameServer, 0, len(manager.GameServers))
+
+	for _, game := range manager.GameServers {
+		if matchesQuery(game, query) {
+			games = append(games, game)
+		}
+	}
+
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].UID < games[j].UID
+	})
+
+	return games
+}
+
+// Returns name of player or empty string when place is free
+func listedPlayerName(player *Player) string {
+	if player == nil {
+		return ""
+	}
+
+	return communication.Escape(player.userName)
+}
+
+// Builds description of game, keys end with given suffix, changes only when game changes
+func BuildGameSummary(game *GameServer, suffix string) string {
+	msg := fmt.Sprintf("gameID%s:%d;", suffix, game.UID)
+	msg += fmt.Sprintf("creator%s:%s;", suffix, communication.Escape(game.Creator))
+	msg += fmt.Sprintf("player1Name%s:%s;", suffix, listedPlayerName(game.Player1))
+	msg += fmt.Sprintf("player2Name%s:%s;", suffix, listedPlayerName(game.Player2))
+	msg += fmt.Sprintf("state%s:%s;", suffix, game.State)
+	msg += fmt.Sprintf("preset%s:%s;", suffix, game.Rules.Preset)
+	msg += fmt.Sprintf("scoreLimit%s:%d;", suffix, game.Rules.ScoreLimit)
+	msg += fmt.Sprintf("score1%s:%d;", suffix, game.Score1)
+	msg += fmt.Sprintf("score2%s:%d;", suffix, game.Score2)
+	msg += fmt.Sprintf("spectators%s:%d;", suffix, len(GameSpectators(game)))
+
+	return msg
+}
+
+// Builds description of game at given position of game list
+func BuildGameListEntry(game *GameServer, index int) string {
+	suffix := strconv.Itoa(index)
+
+	return BuildGameSummary(game, suffix) + fmt.Sprintf("age%s:%d;", suffix, int(time.Since(game.Created).Seconds()))
+}
+
+// Sends lobby event to every subscribed player
+func sendLobbyEvent(manager *Manager, event string, content string) {
+	data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;event:%s;%s>", actionLobbyEvent, event, content))
+
+	for _, player := range manager.Players {
+		if player.lobbySubscribed {
+			SendToPlayer(manager, player, data)
+		}
+	}
+}
+
+// Compares listed games with last published state and informs subscribed players about differences
+func PublishLobbyChanges(manager *Manager) {
+	games := FilterGames(manager, GameListQuery{Filter: filterAll})
+	listed := make(map[int]bool, len(games))
+
+	for _, game := range games {
+		listed[game.UID] = true
+		summary := BuildGameSummary(game, "")
+		previous, known := manager.lobbySnapshot[game.UID]
+
+		if known && previous == summary {
+			continue
+		}
+
+		manager.lobbySnapshot[game.UID] = summary
+
+		event := lobbyGameUpdated
+		if !known {
+			event = lobbyGameCreated
+		}
+
+		sendLobbyEvent(manager, event, summary+fmt.Sprintf("age:%d;", int(time.Since(game.Created).Seconds())))
+	}
+
+	for gameID := range manager.lobbySnapshot {
+		if listed[gameID] {
+			continue
+		}
+
+		delete(manager.lobbySnapshot, gameID)
+		sendLobbyEvent(manager, lobbyGameRemoved, fmt.Sprintf("gameID:%d;", gameID))
+	}
+}
+
+// Player subscribes lobby events, subscribe:false stops them
+func LobbySubscribeAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("lobbySubscribe: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("lobbySubscribe: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot subscribe lobby - User does not exist;>", message.Rid, actionLobbySubscribe))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("lobbySubscribe: User does not exist")
+	}
+
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot subscribe lobby - User is not registered;>", message.Rid, actionLobbySubscribe))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("lobbySubscribe: User not registered")
+	}
+
+	subscribe := true
+	subscribeValue, subscribePresent := message.Content["subscribe"]
+
+	if subscribePresent {
+		parsed, errParse := strconv.ParseBool(subscribeValue)
+
+		if errParse != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot subscribe lobby - subscribe must be true or false;>", message.Rid, actionLobbySubscribe))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("lobbySubscribe: subscribe is not bool")
+		}
+
+		subscribe = parsed
+	}
+
+	// Bring snapshot up to date so events continue right after listed games
+	PublishLobbyChanges(manager)
+
+	player.lobbySubscribed = subscribe
+
+	if !subscribe {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Lobby unsubscribed;subscribed:false;>", message.Rid, actionLobbySubscribe))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return nil
+	}
+
+	// Subscriber starts with all listed games
+	games := FilterGames(manager, GameListQuery{Filter: filterAll})
+
+	sendMessage := fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Lobby subscribed;subscribed:true;gameCount:%d;", message.Rid, actionLobbySubscribe, len(games))
+
+	for id, game := range games {
+		sendMessage = sendMessage + BuildGameListEntry(game, id)
+	}
+
+	sendMessage = sendMessage + ">"
+
+	_ = communication.SendID(manager.CommunicationServer, []byte(sendMessage), message.Source)
+
+	// Subscriber joins lobby chat
+	ReplayLobbyChat(manager, player)
+
+	return nil
+}
+
+// Informs other players and spectators of game that player on given side joined, left or reconnected
+func NotifyGameMembers(manager *Manager, game *GameServer, player *Player, side int, event string) {
+	data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:%s %s;event:%s;gameID:%d;player:%d;name:%s;bot:%t;>", actionGameMembers, communication.Escape(player.userName), event, event, game.UID, side, communication.Escape(player.userName), IsBot(player)))
+
+	for _, member := range []*Player{game.Player1, game.Player2} {
+		if member != nil && member.ID != player.ID {
+			SendToPlayer(manager, member, data)
+		}
+	}
+
+	for _, spectator := range GameSpectators(game) {
+		SendToPlayer(manager, spectator, data)
+	}
+}
@@ -4,6 +4,14 @@ import (
 	"../communication"
 	"errors"
 	"fmt"
+	"time"
+)
+
+const (
+	// How often manager does housekeeping
+	housekeepingInterval = time.Second
+	// How many ended games can wait for manager before game goroutines block
+	finishedGamesBuffer = 64
 )
 
 type Manager struct {
@@ -14,8 +22,40 @@ type Manager struct {
 	MessageChannel      chan communication.Message
 	CommunicationServer *communication.Server
 	ServerActions       Actions
+	// Directory to store replays to, empty if games are not recorded
+	ReplayDirectory     string
+	// Storage of persistent accounts, nil if accounts are not available
+	Accounts            AccountStorage
+	// Session token expires when player does not communicate this long
+	SessionIdle         time.Duration
+	// Rematches players of finished games can agree on (by finished game ID)
+	Rematches           map[int]*Rematch
+	// Players waiting for quick play opponent, longest waiting first
+	Queue               []*QueueEntry
+	// Challenges waiting for answer of challenged player (by challenge ID)
+	Challenges          map[int]*Challenge
 	nextPlayerID        int
 	nextGameID          int
+	nextSeriesID        int
+	nextChallengeID     int
+	// Last published lobby entries of listed games (by game ID)
+	lobbySnapshot       map[int]string
+	// Last messages of lobby chat
+	lobbyChat           []ChatEntry
+	// Games whose loop ended, handed over from game goroutines
+	finishedGames       chan *GameServer
+	// Series games waiting for end of break between games
+	pendingSeries       []*PendingSeriesGame
+	// Password jobs waiting or running
+	passwordJobs        chan struct{}
+	// Running password workers
+	passwordWorkers     chan struct{}
+	// Results of password workers
+	accountResults      chan *AccountResult
+	// Names of accounts being created
+	pendingNames        map[string]bool
+	// Failed logins to accounts (by account name)
+	loginFailures       map[string]*LoginFailures
 }
 
 // Initializes
@@ -31,12 +71,27 @@ func ManagerInitialize(communicationServer *communication.Server) (*Manager, err
 	var manager *Manager = &Manager{
 		Players:             players,
 		GameServers:         games,
+		Rematches:           make(map[int]*Rematch),
+		Challenges:          make(map[int]*Challenge),
+		SessionIdle:         defaultSessionIdle,
+		lobbySnapshot:       make(map[int]string),
+		finishedGames:       make(chan *GameServer, finishedGamesBuffer),
+		passwordJobs:        make(chan struct{}, passwordJobs),
+		passwordWorkers:     make(chan struct{}, passwordWorkers),
+		accountResults:      make(chan *AccountResult, passwordJobs),
+		pendingNames:        make(map[string]bool),
+		loginFailures:       make(map[string]*LoginFailures),
 		MessageChannel:      messages,
 		CommunicationServer: communicationServer,
 		nextPlayerID:        1,
 		nextGameID:          1,
+		nextSeriesID:        1,
+		nextChallengeID:     1,
 	}
 
+	// Chat texts are longer than other values
+	communication.SetValueLimit(actionChat, chatTextKey, maxChatLength)
+
 	// Initialize actions
 	errActionsInit := InitializeActions(manager)
 
@@ -54,13 +109,35 @@ func ManagerInitialize(communicationServer *communication.Server) (*Manager, err
 func ManagerStart(communicationServer *communication.Server, manager *Manager) {
 	defer communicationServer.WaitGroup.Done()
 
+	housekeeping := time.NewTicker(housekeepingInterval)
+	defer housekeeping.Stop()
+
 	for {
-		message := <-communicationServer.MessageChannel
-		//fmt.Printf("Message: %v\n", message)
-		_ = ProcessMessage(manager, &message)
+		select {
+		case message := <-communicationServer.MessageChannel:
+			//fmt.Printf("Message: %v\n", message)
+			_ = ProcessMessage(manager, &message)
+		case game := <-manager.finishedGames:
+			FinishGame(manager, game)
+		case result := <-manager.accountResults:
+			FinishAccountWork(manager, result)
+		case <-housekeeping.C:
+			ManagerHousekeeping(manager)
+		}
 	}
 }
 
+// Does periodic work of manager - matchmaking, series games, bots, expiring rematches, challenges and failed logins and lobby events
+func ManagerHousekeeping(manager *Manager) {
+	MatchPlayers(manager)
+	StartPendingSeriesGames(manager)
+	AddWaitingBots(manager)
+	PruneRematches(manager)
+	PruneChallenges(manager)
+	PruneLoginFailures(manager)
+	PublishLobbyChanges(manager)
+}
+
 func ManagerAddGameServer(manager *Manager, server *GameServer) error {
 	if manager == nil {
 		return errors.New("cannot add game server to manager: manager is NULL")
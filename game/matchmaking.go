@@ -0,0 +1,337 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// Rating of new player
+	initialRating = 1000
+	// How much rating changes after single game at most
+	ratingFactor = 32
+	// Rating difference of players matched right after they entered queue
+	ratingWindow = 100
+	// Rating window grows by this value for every 10 seconds of waiting
+	ratingWindowGrowth = 50
+	// Region which matches every other region
+	anyRegion = "any"
+	// Longest allowed region tag
+	maxRegionLength = 16
+)
+
+// Player waiting for quick play opponent
+type QueueEntry struct {
+	Player *Player
+	// Preset of rules player wants to play
+	Preset string
+	// Region tag player wants to play in
+	Region string
+	// Time when player entered queue
+	Joined time.Time
+	// Difficulty of bot which replaces opponent nobody found in time (empty for no bot)
+	Bot      string
+	BotAfter time.Duration
+	// Last position reported to player
+	position int
+}
+
+// Returns position of player in queue (from 1), 0 if he is not queued
+func QueuePosition(manager *Manager, player *Player) int {
+	for i, entry := range manager.Queue {
+		if entry.Player.ID == player.ID {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// Removes player from queue
+func LeaveQueue(manager *Manager, player *Player) error {
+	position := QueuePosition(manager, player)
+
+	if position == 0 {
+		return errors.New("player is not in queue")
+	}
+
+	manager.Queue = append(manager.Queue[:position-1], manager.Queue[position:]...)
+
+	return nil
+}
+
+// Returns rating difference two players can be matched with
+func matchWindow(first *QueueEntry, second *QueueEntry) float64 {
+	waited := math.Max(time.Since(first.Joined).Seconds(), time.Since(second.Joined).Seconds())
+
+	return ratingWindow + ratingWindowGrowth*math.Floor(waited/10)
+}
+
+// Returns if two queued players can play together
+func isCompatible(first *QueueEntry, second *QueueEntry) bool {
+	if first.Preset != second.Preset {
+		return false
+	}
+
+	if first.Region != second.Region && first.Region != anyRegion && second.Region != anyRegion {
+		return false
+	}
+
+	if !IsAlive(first.Player) || !IsAlive(second.Player) {
+		return false
+	}
+
+	return math.Abs(float64(first.Player.rating-second.Player.rating)) <= matchWindow(first, second)
+}
+
+// Creates game for matched players and informs them
+func StartQueuedGame(manager *Manager, first *QueueEntry, second *QueueEntry) error {
+	game, errCreate := CreateConfiguredGame(manager, first.Player, rulePresets[first.Preset], time.Now().UnixNano())
+
+	if errCreate != nil {
+		return errCreate
+	}
+
+	game.Player1 = first.Player
+
+	if second != nil {
+		game.Player2 = second.Player
+	} else {
+		_, errBot := AddBot(manager, game, first.Bot)
+
+		if errBot != nil {
+			return errBot
+		}
+	}
+
+	rules := BuildRulesContent(game.Rules)
+
+	for side, player := range []*Player{game.Player1, game.Player2} {
+		opponent := game.Player2
+		if side == 1 {
+			opponent = game.Player1
+		}
+
+		data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Opponent found;queue:matched;GameID:%d;player:%d;opponent:%s;opponentRating:%d;%s>", actionQuickPlay, game.UID, side+1, communication.Escape(opponent.userName), opponent.rating, rules))
+		SendToPlayer(manager, player, data)
+	}
+
+	fmt.Printf("matchmaking: %s and %s matched in game #%d\n", game.Player1.userName, game.Player2.userName, game.UID)
+
+	go GameStart(manager, game)
+
+	return nil
+}
+
+// Pairs compatible players in queue, fills long waits with bots and reports queue positions
+func MatchPlayers(manager *Manager) {
+	// Forget players who left server or found game elsewhere
+	waiting := make([]*QueueEntry, 0, len(manager.Queue))
+
+	for _, entry := range manager.Queue {
+		if manager.Players[entry.Player.ID] == entry.Player && isFreeForGame(manager, entry.Player) {
+			waiting = append(waiting, entry)
+		}
+	}
+
+	manager.Queue = waiting
+
+	// Pair players, those waiting longest first
+	matched := make(map[int]bool)
+
+	for i, first := range manager.Queue {
+		if matched[i] {
+			continue
+		}
+
+		for j := i + 1; j < len(manager.Queue); j++ {
+			second := manager.Queue[j]
+
+			if matched[j] || !isCompatible(first, second) {
+				continue
+			}
+
+			if StartQueuedGame(manager, first, second) == nil {
+				matched[i] = true
+				matched[j] = true
+			}
+			break
+		}
+
+		// Nobody came in time - play against bot
+		if !matched[i] && first.Bot != "" && time.Since(first.Joined) >= first.BotAfter {
+			if StartQueuedGame(manager, first, nil) == nil {
+				matched[i] = true
+			}
+		}
+	}
+
+	waiting = make([]*QueueEntry, 0, len(manager.Queue))
+
+	for i, entry := range manager.Queue {
+		if !matched[i] {
+			waiting = append(waiting, entry)
+		}
+	}
+
+	manager.Queue = waiting
+
+	// Inform players whose position changed
+	for i, entry := range manager.Queue {
+		if entry.position == i+1 {
+			continue
+		}
+
+		entry.position = i + 1
+
+		data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Waiting for opponent;queue:position;position:%d;size:%d;>", actionQuickPlay, entry.position, len(manager.Queue)))
+		SendToPlayer(manager, entry.Player, data)
+	}
+}
+
+// Updates ratings of players after game
+func UpdateRatings(game *GameServer, winner int) {
+	if game.Player1 == nil || game.Player2 == nil || IsBot(game.Player1) || IsBot(game.Player2) {
+		return
+	}
+
+	expected := 1 / (1 + math.Pow(10, float64(game.Player2.rating-game.Player1.rating)/400))
+
+	result := 0.0
+	if winner == 1 {
+		result = 1
+	}
+
+	change := int(math.Round(ratingFactor * (result - expected)))
+
+	game.Player1.rating += change
+	game.Player2.rating -= change
+}
+
+// Player asks for quick play opponent, cancel:true leaves queue
+func QuickPlayAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("quickPlay: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("quickPlay: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - User does not exist;>", message.Rid, actionQuickPlay))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("quickPlay: User does not exist")
+	}
+
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - User is not registered;>", message.Rid, actionQuickPlay))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("quickPlay: User not registered")
+	}
+
+	// Player leaves queue
+	cancelValue, cancelPresent := message.Content["cancel"]
+
+	if cancelPresent {
+		cancel, errParse := strconv.ParseBool(cancelValue)
+
+		if errParse != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - cancel must be true or false;>", message.Rid, actionQuickPlay))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("quickPlay: cancel is not bool")
+		}
+
+		if cancel {
+			errLeave := LeaveQueue(manager, player)
+
+			if errLeave != nil {
+				data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play not cancelled - %s;>", message.Rid, actionQuickPlay, errLeave.Error()))
+				_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+				return fmt.Errorf("quickPlay: %s", errLeave.Error())
+			}
+
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Quick play cancelled;queue:cancelled;>", message.Rid, actionQuickPlay))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return nil
+		}
+	}
+
+	if QueuePosition(manager, player) != 0 {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - Already in queue;>", message.Rid, actionQuickPlay))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("quickPlay: already in queue")
+	}
+
+	if !isFreeForGame(manager, player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - Already in another game;>", message.Rid, actionQuickPlay))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("quickPlay: already in another game")
+	}
+
+	// Read preferences
+	preset := DefaultRules().Preset
+	presetValue, presetPresent := message.Content["preset"]
+
+	if presetPresent {
+		_, presetExist := rulePresets[presetValue]
+
+		if !presetExist {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - unknown preset %s;>", message.Rid, actionQuickPlay, presetValue))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("quickPlay: unknown preset")
+		}
+
+		preset = presetValue
+	}
+
+	region := anyRegion
+	regionValue, regionPresent := message.Content["region"]
+
+	if regionPresent {
+		if regionValue == "" || len(regionValue) > maxRegionLength {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - region must have 1 to %d characters;>", message.Rid, actionQuickPlay, maxRegionLength))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("quickPlay: invalid region")
+		}
+
+		region = regionValue
+	}
+
+	botDifficulty, botAfter, errBot := ParseBotSettings(message.Content)
+
+	if errBot != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Quick play failed - %s;>", message.Rid, actionQuickPlay, errBot.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("quickPlay: %s", errBot.Error())
+	}
+
+	// Player stops watching other game
+	_, _ = StopSpectating(manager, player)
+
+	entry := &QueueEntry{
+		Player:   player,
+		Preset:   preset,
+		Region:   region,
+		Joined:   time.Now(),
+		Bot:      botDifficulty,
+		BotAfter: botAfter,
+	}
+
+	manager.Queue = append(manager.Queue, entry)
+	entry.position = len(manager.Queue)
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Waiting for opponent;queue:joined;position:%d;size:%d;preset:%s;region:%s;rating:%d;>", message.Rid, actionQuickPlay, entry.position, len(manager.Queue), preset, region, player.rating))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+	// Try to find opponent right away
+	MatchPlayers(manager)
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Returns side of player in game (1 top, 2 bottom), 0 if player does not play this game
+func PlayerSide(game *GameServer, player *Player) int {
+	if player == nil {
+		return 0
+	}
+
+	if game.Player1 != nil && game.Player1.ID == player.ID {
+		return 1
+	}
+
+	if game.Player2 != nil && game.Player2.ID == player.ID {
+		return 2
+	}
+
+	return 0
+}
+
+// Returns how many pauses player on given side has left
+func PausesLeft(game *GameServer, side int) int {
+	return game.Rules.PauseLimit - game.pauses[side]
+}
+
+// Returns how many ticks of pause player on given side has left
+func PauseTicksLeft(game *GameServer, side int) int {
+	return int(math.Round(game.Rules.PauseTime*float64(game.Tps))) - game.pauseTicks[side]
+}
+
+// Pauses game on players request
+func PauseGame(manager *Manager, game *GameServer, player *Player) error {
+	side := PlayerSide(game, player)
+
+	if side == 0 {
+		return errors.New("player is not in this game")
+	}
+
+	if game.State != StatePlaying && game.State != StateCountdown {
+		return fmt.Errorf("game cannot be paused while %s", game.State)
+	}
+
+	if PausesLeft(game, side) <= 0 {
+		return errors.New("no pauses left")
+	}
+
+	if PauseTicksLeft(game, side) <= 0 {
+		return errors.New("no pause time left")
+	}
+
+	errState := SetGameState(manager, game, StatePausedRequested, fmt.Sprintf("player%d paused game", side))
+
+	if errState != nil {
+		return errState
+	}
+
+	game.pauses[side]++
+	game.pausedBy = side
+	game.currentPauseTicks = 0
+
+	return nil
+}
+
+// Resumes game paused by player, only player who paused game can resume it
+func ResumeGame(manager *Manager, game *GameServer, player *Player) error {
+	side := PlayerSide(game, player)
+
+	if side == 0 {
+		return errors.New("player is not in this game")
+	}
+
+	if game.State != StatePausedRequested {
+		return errors.New("game is not paused")
+	}
+
+	if side != game.pausedBy {
+		return errors.New("only player who paused game can resume it")
+	}
+
+	return SetGameState(manager, game, StateCountdown, fmt.Sprintf("player%d resumed game", side))
+}
+
+// Follows paused flag of players position update, acts only when flag differs from game
+func UpdatePauseFlag(manager *Manager, game *GameServer, player *Player, paused bool) {
+	side := PlayerSide(game, player)
+
+	if paused && (game.State == StatePlaying || game.State == StateCountdown) {
+		_ = PauseGame(manager, game, player)
+	}
+
+	if !paused && game.State == StatePausedRequested && side == game.pausedBy {
+		_ = ResumeGame(manager, game, player)
+	}
+}
+
+// Counts pause time of player who paused game, resumes game when single pause is too long or his time runs out
+func PauseTick(manager *Manager, game *GameServer) {
+	if game.State != StatePausedRequested {
+		return
+	}
+
+	game.pauseTicks[game.pausedBy]++
+	game.currentPauseTicks++
+
+	if PauseTicksLeft(game, game.pausedBy) <= 0 {
+		_ = SetGameState(manager, game, StateCountdown, fmt.Sprintf("pause time of player%d ran out", game.pausedBy))
+		return
+	}
+
+	// Single pause is limited by rules, game resumes even when player has pause time left
+	if game.currentPauseTicks >= int(math.Round(game.Rules.MaxPause*float64(game.Tps))) {
+		_ = SetGameState(manager, game, StateCountdown, fmt.Sprintf("pause of player%d timed out", game.pausedBy))
+	}
+}
+
+// Processes players pause or resume request
+func GamePauseRequest(manager *Manager, server *GameServer, player *Player, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("unable to process pause request: manager cannot be null")
+	}
+
+	if server == nil {
+		return errors.New("unable to process pause request: game server cannot be null")
+	}
+
+	if message == nil {
+		return errors.New("unable to process pause request: message cannot be null")
+	}
+
+	// Check message type
+	if message.Msg != actionPause {
+		return errors.New("unable to process pause request: wrong message type")
+	}
+
+	// Player could leave game before message was processed
+	if player == nil || PlayerSide(server, player) == 0 {
+		return errors.New("unable to process pause request: player is not in this game")
+	}
+
+	// Pause is default, paused:false asks for resume
+	paused := true
+	pausedValue, pausedPresent := message.Content["paused"]
+
+	if pausedPresent {
+		parsed, errConvPaused := strconv.ParseBool(pausedValue)
+
+		if errConvPaused != nil {
+			return errors.New("unable to process pause request: paused must be true or false")
+		}
+
+		paused = parsed
+	}
+
+	var errPause error
+
+	if paused {
+		errPause = PauseGame(manager, server, player)
+	} else {
+		errPause = ResumeGame(manager, server, player)
+	}
+
+	side := PlayerSide(server, player)
+
+	if player.client != nil {
+		var data []byte
+
+		if errPause != nil {
+			data = []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot change pause - %s;pausesLeft:%d;pauseTimeLeft:%.1f;>", message.Rid, actionPause, errPause.Error(), PausesLeft(server, side), float64(PauseTicksLeft(server, side))/float64(server.Tps)))
+		} else {
+			data = []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;paused:%t;pausesLeft:%d;pauseTimeLeft:%.1f;>", message.Rid, actionPause, paused, PausesLeft(server, side), float64(PauseTicksLeft(server, side))/float64(server.Tps)))
+		}
+
+		_ = communication.SendID(manager.CommunicationServer, data, player.client.UID)
+	}
+
+	if errPause != nil {
+		return fmt.Errorf("unable to process pause request: %s", errPause.Error())
+	}
+
+	return nil
+}
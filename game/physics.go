@@ -0,0 +1,387 @@
+package game
+
+import (
+	"math"
+)
+
+const (
+	// Maximum number of collisions resolved during single tick
+	maxCollisionsPerTick = 8
+	// Tolerance for floating point comparisons
+	physicsEpsilon = 1e-9
+	// Ball never leaves paddle flatter than this angle from vertical (degrees)
+	maxBounceDeflection = 75
+)
+
+// Two dimensional vector
+type Vector struct {
+	X float64
+	Y float64
+}
+
+func (v Vector) Add(other Vector) Vector {
+	return Vector{X: v.X + other.X, Y: v.Y + other.Y}
+}
+
+func (v Vector) Sub(other Vector) Vector {
+	return Vector{X: v.X - other.X, Y: v.Y - other.Y}
+}
+
+func (v Vector) Scale(factor float64) Vector {
+	return Vector{X: v.X * factor, Y: v.Y * factor}
+}
+
+func (v Vector) Dot(other Vector) float64 {
+	return v.X*other.X + v.Y*other.Y
+}
+
+func (v Vector) Length() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Returns vector of length 1 with same direction, zero vector stays zero
+func (v Vector) Normalize() Vector {
+	length := v.Length()
+
+	if length < physicsEpsilon {
+		return Vector{}
+	}
+
+	return v.Scale(1 / length)
+}
+
+// Reflects vector by surface with given unit normal
+func (v Vector) Reflect(normal Vector) Vector {
+	return v.Sub(normal.Scale(2 * v.Dot(normal)))
+}
+
+// Returns unit vector for rotation in degrees (0 = right, 90 = down)
+func VectorFromDegrees(degrees float64) Vector {
+	radians := degrees * (math.Pi / 180)
+	return Vector{X: math.Cos(radians), Y: math.Sin(radians)}
+}
+
+// Returns rotation of vector in whole degrees 0-359
+func DegreesFromVector(v Vector) int {
+	degrees := int(math.Round(math.Atan2(v.Y, v.X) * (180 / math.Pi)))
+	return ((degrees % 360) + 360) % 360
+}
+
+// Kinds of surfaces ball can collide with
+const (
+	colliderNone = iota
+	colliderWall
+	colliderPaddle1
+	colliderPaddle2
+)
+
+// Result of swept collision test
+type Collision struct {
+	// Fraction of movement (0-1) when contact happens
+	Time float64
+	// Unit normal of surface at contact point
+	Normal Vector
+	// What was hit
+	Collider int
+	// Contact happened on paddle corner
+	Corner bool
+}
+
+// Axis aligned rectangle given by its corners
+type Rectangle struct {
+	Min Vector
+	Max Vector
+}
+
+// Returns rectangle covered by player paddle
+func PaddleRectangle(player *Player) Rectangle {
+	return Rectangle{
+		Min: Vector{X: player.x - player.width/2, Y: player.y - player.height/2},
+		Max: Vector{X: player.x + player.width/2, Y: player.y + player.height/2},
+	}
+}
+
+// Sweeps circle moving from position by movement against plane given by point and unit normal
+func SweepPlane(position Vector, movement Vector, radius float64, point Vector, normal Vector) (float64, bool) {
+	approach := movement.Dot(normal)
+
+	// Circle is moving away from plane or along it
+	if approach >= 0 {
+		return 0, false
+	}
+
+	distance := position.Sub(point).Dot(normal) - radius
+
+	// Circle already touches plane
+	if distance <= 0 {
+		return 0, true
+	}
+
+	time := distance / -approach
+
+	if time > 1 {
+		return 0, false
+	}
+
+	return time, true
+}
+
+// Sweeps circle moving from position by movement against circle with given centre and radius
+func SweepCircle(position Vector, movement Vector, centre Vector, radius float64) (float64, bool) {
+	offset := position.Sub(centre)
+	a := movement.Dot(movement)
+	b := 2 * offset.Dot(movement)
+	c := offset.Dot(offset) - radius*radius
+
+	// Already overlapping - collide only if moving closer
+	if c <= 0 {
+		return 0, b < 0
+	}
+
+	if a < physicsEpsilon {
+		return 0, false
+	}
+
+	discriminant := b*b - 4*a*c
+
+	if discriminant < 0 {
+		return 0, false
+	}
+
+	time := (-b - math.Sqrt(discriminant)) / (2 * a)
+
+	if time < 0 || time > 1 {
+		return 0, false
+	}
+
+	return time, true
+}
+
+// Sweeps circle moving from position by movement against rectangle - faces, edges and corners
+func SweepRectangle(position Vector, movement Vector, radius float64, rectangle Rectangle) (Collision, bool) {
+	// Rectangle grown by radius on all sides (Minkowski sum without rounded corners)
+	expanded := Rectangle{
+		Min: rectangle.Min.Sub(Vector{X: radius, Y: radius}),
+		Max: rectangle.Max.Add(Vector{X: radius, Y: radius}),
+	}
+
+	enter := math.Inf(-1)
+	exit := math.Inf(1)
+	normal := Vector{}
+
+	axes := []struct {
+		position float64
+		movement float64
+		min      float64
+		max      float64
+		normal   Vector
+	}{
+		{position.X, movement.X, expanded.Min.X, expanded.Max.X, Vector{X: 1}},
+		{position.Y, movement.Y, expanded.Min.Y, expanded.Max.Y, Vector{Y: 1}},
+	}
+
+	for _, axis := range axes {
+		if math.Abs(axis.movement) < physicsEpsilon {
+			// No movement on this axis - must already be inside slab
+			if axis.position < axis.min || axis.position > axis.max {
+				return Collision{}, false
+			}
+			continue
+		}
+
+		near := (axis.min - axis.position) / axis.movement
+		far := (axis.max - axis.position) / axis.movement
+		faceNormal := axis.normal.Scale(-1)
+
+		if near > far {
+			near, far = far, near
+			faceNormal = axis.normal
+		}
+
+		if near > enter {
+			enter = near
+			normal = faceNormal
+		}
+
+		if far < exit {
+			exit = far
+		}
+	}
+
+	// Missed expanded rectangle or hit it outside of this tick
+	if enter > exit || exit <= 0 || enter > 1 {
+		return Collision{}, false
+	}
+
+	// Started inside - only collide when moving deeper
+	if enter < 0 {
+		if normal.Dot(movement) >= 0 {
+			return Collision{}, false
+		}
+		enter = 0
+	}
+
+	// Contact point of circle centre with expanded rectangle
+	contact := position.Add(movement.Scale(enter))
+
+	// Determine if contact is in corner region of expanded rectangle
+	corner := Vector{}
+	cornerX := false
+	cornerY := false
+
+	if contact.X < rectangle.Min.X {
+		corner.X = rectangle.Min.X
+		cornerX = true
+	} else if contact.X > rectangle.Max.X {
+		corner.X = rectangle.Max.X
+		cornerX = true
+	}
+
+	if contact.Y < rectangle.Min.Y {
+		corner.Y = rectangle.Min.Y
+		cornerY = true
+	} else if contact.Y > rectangle.Max.Y {
+		corner.Y = rectangle.Max.Y
+		cornerY = true
+	}
+
+	if !cornerX || !cornerY {
+		// Face or edge hit
+		return Collision{Time: enter, Normal: normal}, true
+	}
+
+	// Corner hit - test against rounded corner
+	time, hit := SweepCircle(position, movement, corner, radius)
+
+	if !hit {
+		return Collision{}, false
+	}
+
+	cornerNormal := position.Add(movement.Scale(time)).Sub(corner).Normalize()
+
+	if cornerNormal.Length() < physicsEpsilon {
+		cornerNormal = normal
+	}
+
+	return Collision{Time: time, Normal: cornerNormal, Corner: true}, true
+}
+
+// Finds earliest collision of ball moving by movement during current tick
+func FindCollision(server *GameServer, position Vector, movement Vector) (Collision, bool) {
+	radius := float64(server.Ball.Size)
+	width := float64(server.WIDTH)
+	height := float64(server.HEIGHT)
+
+	earliest := Collision{Time: math.Inf(1), Collider: colliderNone}
+
+	consider := func(collision Collision, hit bool) {
+		if hit && collision.Time < earliest.Time {
+			earliest = collision
+		}
+	}
+
+	// Side walls
+	time, hit := SweepPlane(position, movement, radius, Vector{X: 0}, Vector{X: 1})
+	consider(Collision{Time: time, Normal: Vector{X: 1}, Collider: colliderWall}, hit)
+
+	time, hit = SweepPlane(position, movement, radius, Vector{X: width}, Vector{X: -1})
+	consider(Collision{Time: time, Normal: Vector{X: -1}, Collider: colliderWall}, hit)
+
+	// Top side - paddle or wall when there is nobody to play
+	if server.Player1 != nil {
+		collision, hitPaddle := SweepRectangle(position, movement, radius, PaddleRectangle(server.Player1))
+		collision.Collider = colliderPaddle1
+		consider(collision, hitPaddle)
+	} else {
+		time, hit = SweepPlane(position, movement, radius, Vector{Y: 0}, Vector{Y: 1})
+		consider(Collision{Time: time, Normal: Vector{Y: 1}, Collider: colliderWall}, hit)
+	}
+
+	// Bottom side - paddle or wall when there is nobody to play
+	if server.Player2 != nil {
+		collision, hitPaddle := SweepRectangle(position, movement, radius, PaddleRectangle(server.Player2))
+		collision.Collider = colliderPaddle2
+		consider(collision, hitPaddle)
+	} else {
+		time, hit = SweepPlane(position, movement, radius, Vector{Y: height}, Vector{Y: -1})
+		consider(Collision{Time: time, Normal: Vector{Y: -1}, Collider: colliderWall}, hit)
+	}
+
+	return earliest, earliest.Collider != colliderNone
+}
+
+// Returns if ball hit flat front or back face of paddle (not its edge or corner)
+func IsFaceHit(collision Collision) bool {
+	return !collision.Corner && collision.Normal.X == 0 && collision.Normal.Y != 0
+}
+
+// Returns direction of ball after paddle face hit - depends on where ball hit paddle and how paddle moved
+func PaddleBounce(server *GameServer, player *Player, position Vector, normal Vector) Vector {
+	// Position of contact relative to paddle centre: -1 left edge, 0 centre, 1 right edge
+	reach := player.width/2 + float64(server.Ball.Size)
+	offset := (position.X - player.x) / reach
+	offset = math.Max(-1, math.Min(1, offset))
+
+	radians := offset * server.BOUNCE_ANGLE * (math.Pi / 180)
+	direction := Vector{X: math.Sin(radians), Y: normal.Y * math.Cos(radians)}
+
+	// Spin - moving paddle drags ball along
+	if server.Ball.Speed > physicsEpsilon {
+		direction.X += server.SPIN_FACTOR * PlayerVelocity(player) / server.Ball.Speed
+	}
+
+	direction = direction.Normalize()
+
+	// Keep ball from travelling almost horizontally
+	limit := math.Sin(maxBounceDeflection * (math.Pi / 180))
+
+	if math.Abs(direction.X) > limit {
+		direction.X = math.Copysign(limit, direction.X)
+		direction.Y = math.Copysign(math.Sqrt(1-limit*limit), normal.Y)
+	}
+
+	return direction
+}
+
+// Moves ball by its velocity for single tick, resolving every collision on the way
+func StepBall(server *GameServer) {
+	ball := server.Ball
+	position := Vector{X: ball.X, Y: ball.Y}
+	remaining := 1.0
+
+	for i := 0; i < maxCollisionsPerTick && remaining > physicsEpsilon; i++ {
+		movement := ball.Direction.Scale(ball.Speed * remaining)
+		collision, hit := FindCollision(server, position, movement)
+
+		if !hit {
+			position = position.Add(movement)
+			break
+		}
+
+		// Move to contact point and bounce
+		position = position.Add(movement.Scale(collision.Time))
+
+		if collision.Collider == colliderPaddle1 && IsFaceHit(collision) {
+			ball.Direction = PaddleBounce(server, server.Player1, position, collision.Normal)
+		} else if collision.Collider == colliderPaddle2 && IsFaceHit(collision) {
+			ball.Direction = PaddleBounce(server, server.Player2, position, collision.Normal)
+		} else {
+			ball.Direction = ball.Direction.Reflect(collision.Normal).Normalize()
+		}
+
+		if collision.Collider == colliderPaddle1 || collision.Collider == colliderPaddle2 {
+			// Add speed
+			ball.Speed += server.Rules.BallAcceleration
+			// Check max speed
+			if ball.Speed > ball.MaxSpeed {
+				ball.Speed = ball.MaxSpeed
+			}
+		}
+
+		remaining *= 1 - collision.Time
+	}
+
+	ball.X = position.X
+	ball.Y = position.Y
+	ball.Rotation = DegreesFromVector(ball.Direction)
+}
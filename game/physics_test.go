@@ -0,0 +1,229 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+// Allowed difference of compared floats
+const testEpsilon = 1e-6
+
+// Creates game with both paddles in centre and ball of given size, position, rotation and speed
+func newPhysicsGame(size int, x float64, y float64, rotation int, speed float64) *GameServer {
+	game := &GameServer{
+		WIDTH:              375,
+		HEIGHT:             600,
+		PLAYER_SIZE_WIDTH:  80,
+		PLAYER_SIZE_HEIGHT: 3,
+		PLAYER_GAP:         8,
+		BOUNCE_ANGLE:       60,
+		Rules:              Rules{BallAcceleration: 1},
+	}
+
+	game.Player1 = &Player{ID: 1}
+	game.Player2 = &Player{ID: 2}
+	_ = PlacePlayer(game, game.Player1)
+	_ = PlacePlayer(game, game.Player2)
+
+	game.Ball = &Ball{X: x, Y: y, Speed: speed, MaxSpeed: 15, Size: size}
+	SetBallRotation(game.Ball, rotation)
+
+	return game
+}
+
+// Returns if floats are equal up to rounding
+func nearlyEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < testEpsilon
+}
+
+func TestSweepCircle(t *testing.T) {
+	cases := []struct {
+		name     string
+		position Vector
+		movement Vector
+		hit      bool
+		time     float64
+	}{
+		{"head on", Vector{X: -20}, Vector{X: 20}, true, 0.5},
+		{"passes by", Vector{X: -20, Y: 20}, Vector{X: 40}, false, 0},
+		{"too far for tick", Vector{X: -40}, Vector{X: 10}, false, 0},
+		{"overlapping moving away", Vector{X: 5}, Vector{X: 5}, false, 0},
+		{"overlapping moving closer", Vector{X: 5}, Vector{X: -5}, true, 0},
+	}
+
+	for _, c := range cases {
+		time, hit := SweepCircle(c.position, c.movement, Vector{}, 10)
+
+		if hit != c.hit || (hit && !nearlyEqual(time, c.time)) {
+			t.Errorf("%s: got hit %t at %f, expected hit %t at %f", c.name, hit, time, c.hit, c.time)
+		}
+	}
+}
+
+func TestSweepRectangle(t *testing.T) {
+	rectangle := Rectangle{Min: Vector{X: 0, Y: 0}, Max: Vector{X: 80, Y: 3}}
+	diagonal := math.Sqrt(800)
+
+	cases := []struct {
+		name     string
+		position Vector
+		movement Vector
+		hit      bool
+		time     float64
+		normal   Vector
+		corner   bool
+	}{
+		{"top face", Vector{X: 40, Y: -20}, Vector{Y: 20}, true, 0.5, Vector{Y: -1}, false},
+		{"bottom face", Vector{X: 10, Y: 23}, Vector{Y: -20}, true, 0.5, Vector{Y: 1}, false},
+		{"left edge", Vector{X: -20, Y: 1.5}, Vector{X: 20}, true, 0.5, Vector{X: -1}, false},
+		{"right edge", Vector{X: 100, Y: 1.5}, Vector{X: -20}, true, 0.5, Vector{X: 1}, false},
+		{"corner", Vector{X: -20, Y: -20}, Vector{X: 20, Y: 20}, true, (diagonal - 10) / diagonal, Vector{X: -1, Y: -1}.Normalize(), true},
+		{"passes corner", Vector{X: -20, Y: -20}, Vector{X: 10, Y: 15}, false, 0, Vector{}, false},
+		{"passes by", Vector{X: -20, Y: -20}, Vector{Y: 40}, false, 0, Vector{}, false},
+		{"too far for tick", Vector{X: 40, Y: -50}, Vector{Y: 20}, false, 0, Vector{}, false},
+	}
+
+	for _, c := range cases {
+		collision, hit := SweepRectangle(c.position, c.movement, 10, rectangle)
+
+		if hit != c.hit {
+			t.Errorf("%s: got hit %t, expected %t", c.name, hit, c.hit)
+			continue
+		}
+
+		if !hit {
+			continue
+		}
+
+		if !nearlyEqual(collision.Time, c.time) || !nearlyEqual(collision.Normal.X, c.normal.X) || !nearlyEqual(collision.Normal.Y, c.normal.Y) || collision.Corner != c.corner {
+			t.Errorf("%s: got %+v, expected time %f, normal %+v, corner %t", c.name, collision, c.time, c.normal, c.corner)
+		}
+	}
+}
+
+func TestFindCollision(t *testing.T) {
+	cases := []struct {
+		name     string
+		position Vector
+		movement Vector
+		// Bottom player left game
+		noPlayer2 bool
+		hit       bool
+		time      float64
+		collider  int
+		normal    Vector
+	}{
+		{"left wall", Vector{X: 15, Y: 300}, Vector{X: -10}, false, true, 0.5, colliderWall, Vector{X: 1}},
+		{"right wall", Vector{X: 360, Y: 300}, Vector{X: 10}, false, true, 0.5, colliderWall, Vector{X: -1}},
+		{"top paddle", Vector{X: 187, Y: 30}, Vector{Y: -15}, false, true, 0.7, colliderPaddle1, Vector{Y: 1}},
+		{"bottom paddle", Vector{X: 187, Y: 570}, Vector{Y: 15}, false, true, 0.7, colliderPaddle2, Vector{Y: -1}},
+		{"bottom wall without player", Vector{X: 187, Y: 585}, Vector{Y: 10}, true, true, 0.5, colliderWall, Vector{Y: -1}},
+		{"free flight", Vector{X: 187, Y: 300}, Vector{X: 5, Y: 5}, false, false, 0, colliderNone, Vector{}},
+	}
+
+	for _, c := range cases {
+		game := newPhysicsGame(10, c.position.X, c.position.Y, 0, 0)
+
+		if c.noPlayer2 {
+			game.Player2 = nil
+		}
+
+		collision, hit := FindCollision(game, c.position, c.movement)
+
+		if hit != c.hit {
+			t.Errorf("%s: got hit %t, expected %t", c.name, hit, c.hit)
+			continue
+		}
+
+		if !hit {
+			continue
+		}
+
+		if collision.Collider != c.collider || !nearlyEqual(collision.Time, c.time) || !nearlyEqual(collision.Normal.X, c.normal.X) || !nearlyEqual(collision.Normal.Y, c.normal.Y) {
+			t.Errorf("%s: got %+v, expected collider %d at %f with normal %+v", c.name, collision, c.collider, c.time, c.normal)
+		}
+	}
+}
+
+// Rest of tick after hit is travelled with increased speed
+func TestStepBall(t *testing.T) {
+	cases := []struct {
+		name     string
+		size     int
+		x        float64
+		y        float64
+		rotation int
+		speed    float64
+		ticks    int
+		check    func(ball *Ball) bool
+	}{
+		{"bottom paddle face", 10, 187, 575, 90, 10, 1, func(ball *Ball) bool {
+			return nearlyEqual(ball.Y, 575.55) && ball.Direction.Y < 0 && ball.Speed == 11
+		}},
+		{"top paddle face", 10, 187, 25, 270, 10, 1, func(ball *Ball) bool {
+			return nearlyEqual(ball.Y, 24.45) && ball.Direction.Y > 0 && ball.Speed == 11
+		}},
+		{"paddle edge angles ball", 10, 220, 575, 90, 10, 1, func(ball *Ball) bool {
+			return ball.Direction.Y < 0 && ball.Direction.X > 0
+		}},
+		{"paddle side edge", 10, 130, 592, 0, 10, 1, func(ball *Ball) bool {
+			return ball.Direction.X < 0 && nearlyEqual(ball.Direction.Y, 0) && ball.X <= 137+testEpsilon
+		}},
+		{"paddle corner", 10, 120, 565, 45, 10, 3, func(ball *Ball) bool {
+			return ball.Direction.X < 0 && ball.Direction.Y < 0
+		}},
+		{"left wall", 10, 15, 300, 180, 10, 1, func(ball *Ball) bool {
+			return nearlyEqual(ball.X, 15) && ball.Direction.X > 0
+		}},
+		{"right wall diagonal", 10, 360, 300, 45, 10, 1, func(ball *Ball) bool {
+			return ball.X <= 365+testEpsilon && ball.Direction.X < 0 && ball.Direction.Y > 0
+		}},
+		{"speed limit", 10, 187, 570, 90, 15, 1, func(ball *Ball) bool {
+			return ball.Speed == 15
+		}},
+		{"missed ball passes paddle", 10, 30, 560, 90, 15, 5, func(ball *Ball) bool {
+			return ball.Y > 592
+		}},
+	}
+
+	for _, c := range cases {
+		game := newPhysicsGame(c.size, c.x, c.y, c.rotation, c.speed)
+
+		for i := 0; i < c.ticks; i++ {
+			StepBall(game)
+		}
+
+		if !c.check(game.Ball) {
+			t.Errorf("%s: unexpected ball %+v", c.name, *game.Ball)
+		}
+	}
+}
+
+// Ball at max speed moves more than paddle is thick, it still has to bounce from every paddle it reaches
+func TestStepBallDoesNotTunnel(t *testing.T) {
+	for _, size := range []int{1, 10} {
+		for rotation := 80; rotation <= 100; rotation += 5 {
+			for offset := 0.0; offset < 15; offset += 0.5 {
+				radius := float64(size)
+				paddleTop := 592 - 1.5
+
+				// Bottom paddle, ball touches it during this tick
+				game := newPhysicsGame(size, 187, paddleTop-radius-offset, rotation, 15)
+				StepBall(game)
+
+				if game.Ball.Y > paddleTop-radius+testEpsilon || game.Ball.Direction.Y >= 0 {
+					t.Errorf("size %d, rotation %d, offset %f: ball passed bottom paddle %+v", size, rotation, offset, *game.Ball)
+				}
+
+				// Top paddle, same from other side
+				paddleBottom := 8 + 1.5
+				game = newPhysicsGame(size, 187, paddleBottom+radius+offset, rotation+180, 15)
+				StepBall(game)
+
+				if game.Ball.Y < paddleBottom+radius-testEpsilon || game.Ball.Direction.Y <= 0 {
+					t.Errorf("size %d, rotation %d, offset %f: ball passed top paddle %+v", size, rotation+180, offset, *game.Ball)
+				}
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"../communication"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -28,6 +29,36 @@ type Player struct {
 	height float64
 	// Player width
 	width float64
+	// Distance player can still move during current tick
+	moveBudget float64
+	// x coordination at the beginning of current tick
+	tickStartX float64
+	// Count of rejected impossible movement inputs
+	invalidInputs int
+	// Player controls paddle by movement commands instead of positions
+	commandInput bool
+	// Direction of movement from last command (-1 left, 0 stop, 1 right)
+	moveDirection int
+	// Sequence number of last processed movement command
+	lastInputSeq int
+	// Player confirmed he is ready to start game
+	ready bool
+	// Controller of player played by server, nil for human player
+	controller Controller
+	// Skill rating used by matchmaking
+	rating int
+	// Player receives lobby events
+	lobbySubscribed bool
+	// Chat limits and ignored players
+	chat ChatState
+	// Persistent account of player, nil for guest
+	account *Account
+	// Token client has to present to reconnect as player, empty when session ended
+	sessionToken string
+	// Password of register or login is being hashed
+	authPending bool
+	// Failed logins from players connection
+	loginFailures LoginFailures
 }
 
 
@@ -47,6 +78,11 @@ func CreateUnAuthenticatedPlayer(manager *Manager, clientID int) error {
 		ID:                manager.nextPlayerID,
 		userName:          "",
 		lastCommunication: time.Now().Unix(),
+		rating:            initialRating,
+		chat: ChatState{
+			muted:   make(map[int]bool),
+			blocked: make(map[int]bool),
+		},
 	}
 
 	// Increment new player ID
@@ -112,7 +148,7 @@ func GetPlayerByClientID(manager *Manager, clientID int) (*Player, error) {
 	}
 
 	for _, playerIter := range manager.Players {
-		if playerIter.client.UID == clientID {
+		if playerIter.client != nil && playerIter.client.UID == clientID {
 			return playerIter, nil
 		}
 	}
@@ -120,6 +156,59 @@ func GetPlayerByClientID(manager *Manager, clientID int) (*Player, error) {
 	return nil, errors.New("player with such client ID was not found")
 }
 
+// Player tried to act as other player
+var ErrUnauthorized = errors.New("unauthorized - playerID does not belong to this connection")
+
+// Returns player bound to client which sent message, playerID in message only has to match him
+func ResolveActingPlayer(manager *Manager, message *communication.Message) (*Player, error) {
+	if manager == nil {
+		return nil, errors.New("manager cannot be NULL")
+	}
+
+	if message == nil {
+		return nil, errors.New("message cannot be NULL")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		return nil, errors.New("player does not exist")
+	}
+
+	playerIDValue, playerIDPresent := message.Content["playerID"]
+
+	if !playerIDPresent {
+		return player, nil
+	}
+
+	playerID, errParse := strconv.Atoi(playerIDValue)
+
+	if errParse != nil {
+		return nil, errors.New("playerID must be number")
+	}
+
+	if playerID != player.ID {
+		return nil, ErrUnauthorized
+	}
+
+	return player, nil
+}
+
+// Returns registered Player by his name
+func GetPlayerByName(manager *Manager, name string) (*Player, error) {
+	if manager == nil {
+		return nil, errors.New("manager cannot be NULL")
+	}
+
+	for _, playerIter := range manager.Players {
+		if isAuthenticated(playerIter) && playerIter.userName == name {
+			return playerIter, nil
+		}
+	}
+
+	return nil, errors.New("player with that name does not exist")
+}
+
 // Returns Player Game by Player
 func GetPlayersGame(manager *Manager, player *Player) (*GameServer, error) {
 	if manager == nil {
@@ -170,6 +259,11 @@ func IsAlive(player *Player) bool {
 		return false
 	}
 
+	// Bots never lose connection
+	if IsBot(player) {
+		return true
+	}
+
 	return time.Now().Unix() - player.lastCommunication < 2.0
 }
 
@@ -199,4 +293,12 @@ func RemovePlayer(manager *Manager, player *Player) error {
 
 	delete(manager.Players, player.ID)
 	return nil
-}
\ No newline at end of file
+}
+// Sends data to player if he has connected client
+func SendToPlayer(manager *Manager, player *Player, data []byte) {
+	if player == nil || player.client == nil {
+		return
+	}
+
+	_ = communication.SendID(manager.CommunicationServer, data, player.client.UID)
+}
@@ -0,0 +1,180 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	// Length of invite code of private game
+	inviteCodeLength = 8
+	// Characters of invite code, similar looking characters left out
+	inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// Longest allowed password of private game
+	maxPasswordLength = 64
+)
+
+// Access settings of game
+type Privacy struct {
+	// Game is hidden from game list and can be entered only with invite code
+	Private bool
+	// Code players need to join or watch private game
+	InviteCode string
+	// Optional password required together with invite code
+	password string
+}
+
+// Reads optional privacy settings from create game message, password makes game private
+func ParsePrivacy(manager *Manager, content map[string]string) (Privacy, error) {
+	privacy := Privacy{}
+
+	privateValue, privatePresent := content["private"]
+
+	if privatePresent {
+		private, errParse := strconv.ParseBool(privateValue)
+
+		if errParse != nil {
+			return Privacy{}, errors.New("private must be true or false")
+		}
+
+		privacy.Private = private
+	}
+
+	passwordValue, passwordPresent := content["password"]
+
+	if passwordPresent {
+		if passwordValue == "" || len(passwordValue) > maxPasswordLength {
+			return Privacy{}, fmt.Errorf("password must have 1 to %d characters", maxPasswordLength)
+		}
+
+		privacy.Private = true
+		privacy.password = passwordValue
+	}
+
+	if !privacy.Private {
+		return privacy, nil
+	}
+
+	code, errCode := GenerateInviteCode(manager)
+
+	if errCode != nil {
+		return Privacy{}, errCode
+	}
+
+	privacy.InviteCode = code
+
+	return privacy, nil
+}
+
+// Generates random invite code not used by any other game
+func GenerateInviteCode(manager *Manager) (string, error) {
+	alphabetSize := big.NewInt(int64(len(inviteCodeAlphabet)))
+
+	for {
+		code := make([]byte, inviteCodeLength)
+
+		for i := range code {
+			index, errRandom := rand.Int(rand.Reader, alphabetSize)
+
+			if errRandom != nil {
+				return "", errors.New("cannot generate invite code")
+			}
+
+			code[i] = inviteCodeAlphabet[index.Int64()]
+		}
+
+		_, errFind := GetGameByInviteCode(manager, string(code))
+
+		if errFind != nil {
+			return string(code), nil
+		}
+	}
+}
+
+// Returns private game with given invite code
+func GetGameByInviteCode(manager *Manager, code string) (*GameServer, error) {
+	if manager == nil {
+		return nil, errors.New("manager cannot be NULL")
+	}
+
+	for _, game := range manager.GameServers {
+		if game.Privacy.Private && game.Privacy.InviteCode == code {
+			return game, nil
+		}
+	}
+
+	return nil, errors.New("game with that invite code does not exist")
+}
+
+// Checks invite code and password given in join or spectate message against game settings
+func CheckGameAccess(game *GameServer, content map[string]string) error {
+	if !game.Privacy.Private {
+		return nil
+	}
+
+	code := content["inviteCode"]
+
+	if subtle.ConstantTimeCompare([]byte(code), []byte(game.Privacy.InviteCode)) != 1 {
+		return errors.New("game is private - invite code is missing or wrong")
+	}
+
+	if game.Privacy.password == "" {
+		return nil
+	}
+
+	password := content["password"]
+
+	if subtle.ConstantTimeCompare([]byte(password), []byte(game.Privacy.password)) != 1 {
+		return errors.New("wrong password")
+	}
+
+	return nil
+}
+
+// Finds game requested by join or spectate message, by game ID or by invite code
+func FindRequestedGame(manager *Manager, content map[string]string) (*GameServer, error) {
+	gameIDString, idPresent := content["gameID"]
+
+	if !idPresent {
+		code, codePresent := content["inviteCode"]
+
+		if !codePresent {
+			return nil, errors.New("Missing game ID")
+		}
+
+		game, errFind := GetGameByInviteCode(manager, code)
+
+		if errFind != nil {
+			return nil, errors.New("Game with that invite code does not exist")
+		}
+
+		return game, nil
+	}
+
+	gameID, errParse := strconv.Atoi(gameIDString)
+
+	if errParse != nil {
+		return nil, errors.New("Game ID needs to be number")
+	}
+
+	game, errFind := GetGameByID(manager, gameID)
+
+	if errFind != nil {
+		return nil, errors.New("Game with that ID does not exist")
+	}
+
+	return game, nil
+}
+
+// Builds privacy part of message for players of game
+func BuildPrivacyContent(game *GameServer) string {
+	if !game.Privacy.Private {
+		return "private:false;"
+	}
+
+	return fmt.Sprintf("private:true;inviteCode:%s;passwordProtected:%t;", game.Privacy.InviteCode, game.Privacy.password != "")
+}
@@ -0,0 +1,285 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// How long players can agree on rematch after game end
+const rematchTimeout = 30 * time.Second
+
+// Rematch which can be agreed on by players of finished game
+type Rematch struct {
+	// ID of finished game
+	GameID int
+	// Players by side in finished game
+	Player1 *Player
+	Player2 *Player
+	// Rules of finished game
+	Rules Rules
+	// Private game settings of finished game
+	Privacy Privacy
+	// Players who want rematch (by player ID)
+	offers map[int]bool
+	// Time when rematch can no longer be agreed on
+	Expires time.Time
+}
+
+// Allows players of finished game to play again
+func OpenRematch(manager *Manager, game *GameServer) {
+	if game.Player1 == nil || game.Player2 == nil {
+		return
+	}
+
+	manager.Rematches[game.UID] = &Rematch{
+		GameID:  game.UID,
+		Player1: game.Player1,
+		Player2: game.Player2,
+		Rules:   game.Rules,
+		Privacy: game.Privacy,
+		offers:  make(map[int]bool),
+		Expires: time.Now().Add(rematchTimeout),
+	}
+}
+
+// Removes rematches which were not agreed on in time and informs players
+func PruneRematches(manager *Manager) {
+	for gameID, rematch := range manager.Rematches {
+		if time.Now().Before(rematch.Expires) {
+			continue
+		}
+
+		delete(manager.Rematches, gameID)
+
+		// Inform players who were waiting for answer
+		for _, player := range []*Player{rematch.Player1, rematch.Player2} {
+			if rematch.offers[player.ID] {
+				data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Rematch offer expired;rematch:expired;gameID:%d;>", actionRematch, gameID))
+				SendToPlayer(manager, player, data)
+			}
+		}
+	}
+}
+
+// Returns rematch of players finished game, given game ID is used when player has more of them
+func GetPlayersRematch(manager *Manager, player *Player, gameID int) (*Rematch, error) {
+	var found *Rematch = nil
+
+	for _, rematch := range manager.Rematches {
+		if rematch.Player1.ID != player.ID && rematch.Player2.ID != player.ID {
+			continue
+		}
+
+		if gameID != 0 && rematch.GameID != gameID {
+			continue
+		}
+
+		// Prefer latest game
+		if found == nil || rematch.GameID > found.GameID {
+			found = rematch
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("no rematch available")
+	}
+
+	return found, nil
+}
+
+// Returns opponent of player in rematch
+func rematchOpponent(rematch *Rematch, player *Player) *Player {
+	if rematch.Player1.ID == player.ID {
+		return rematch.Player2
+	}
+
+	return rematch.Player1
+}
+
+// Returns if player can start new game
+func isFreeForGame(manager *Manager, player *Player) bool {
+	game, errGame := GetPlayersGame(manager, player)
+
+	return errGame != nil || !IsGameRunning(game)
+}
+
+// Returns if player is still connected to server, bots live only in their game and are always available
+func isRematchPlayerPresent(manager *Manager, player *Player) bool {
+	if IsBot(player) {
+		return true
+	}
+
+	stored, exist := manager.Players[player.ID]
+
+	return exist && stored == player && IsAlive(player)
+}
+
+// Starts game of rematch with sides swapped
+func StartRematch(manager *Manager, rematch *Rematch) (*GameServer, error) {
+	// Players have to be still here and free
+	for _, player := range []*Player{rematch.Player1, rematch.Player2} {
+		if player == nil || !isRematchPlayerPresent(manager, player) || !isFreeForGame(manager, player) {
+			return nil, errors.New("player is not available")
+		}
+	}
+
+	game, errCreate := CreateConfiguredGame(manager, rematch.Player2, rematch.Rules, time.Now().UnixNano())
+
+	if errCreate != nil {
+		return nil, errCreate
+	}
+
+	game.Player1 = rematch.Player2
+	game.Player2 = rematch.Player1
+	game.Privacy = rematch.Privacy
+
+	delete(manager.Rematches, rematch.GameID)
+
+	go GameStart(manager, game)
+
+	return game, nil
+}
+
+// Player offers or accepts rematch of finished game, accept:false declines it
+func RematchAction(manager *Manager, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("rematch: manager cannot be nil")
+	}
+
+	if message == nil {
+		return errors.New("rematch: message cannot be nil")
+	}
+
+	player, errFindPlayer := GetPlayerByClientID(manager, message.Source)
+
+	if errFindPlayer != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - User does not exist;>", message.Rid, actionRematch))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("rematch: User does not exist")
+	}
+
+	if !isAuthenticated(player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - User is not registered;>", message.Rid, actionRematch))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("rematch: User not registered")
+	}
+
+	// Optional ID of finished game
+	gameID := 0
+	gameIDValue, gameIDPresent := message.Content["gameID"]
+
+	if gameIDPresent {
+		parsed, errParse := strconv.Atoi(gameIDValue)
+
+		if errParse != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - Game ID needs to be number;>", message.Rid, actionRematch))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("rematch: Game ID is not number")
+		}
+
+		gameID = parsed
+	}
+
+	// Accept is default, accept:false declines rematch
+	accept := true
+	acceptValue, acceptPresent := message.Content["accept"]
+
+	if acceptPresent {
+		parsed, errParse := strconv.ParseBool(acceptValue)
+
+		if errParse != nil {
+			data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - accept must be true or false;>", message.Rid, actionRematch))
+			_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+			return errors.New("rematch: accept is not bool")
+		}
+
+		accept = parsed
+	}
+
+	PruneRematches(manager)
+
+	rematch, errRematch := GetPlayersRematch(manager, player, gameID)
+
+	if errRematch != nil {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - %s;>", message.Rid, actionRematch, errRematch.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return fmt.Errorf("rematch: %s", errRematch.Error())
+	}
+
+	opponent := rematchOpponent(rematch, player)
+
+	// Player declines rematch
+	if !accept {
+		delete(manager.Rematches, rematch.GameID)
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Rematch declined;rematch:declined;gameID:%d;>", message.Rid, actionRematch, rematch.GameID))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Opponent declined rematch;rematch:declined;gameID:%d;>", actionRematch, rematch.GameID))
+		SendToPlayer(manager, opponent, data)
+		return nil
+	}
+
+	if !isFreeForGame(manager, player) {
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - Already in another game;>", message.Rid, actionRematch))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+		return errors.New("rematch: already in another game")
+	}
+
+	rematch.offers[player.ID] = true
+
+	// Bots always want to play again
+	if IsBot(opponent) {
+		rematch.offers[opponent.ID] = true
+	}
+
+	// Wait for opponent to accept
+	if !rematch.offers[opponent.ID] {
+		expires := int(time.Until(rematch.Expires).Seconds())
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Rematch offered;rematch:offered;gameID:%d;expires:%d;>", message.Rid, actionRematch, rematch.GameID, expires))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Opponent wants rematch;rematch:requested;gameID:%d;opponent:%s;expires:%d;>", actionRematch, rematch.GameID, communication.Escape(player.userName), expires))
+		SendToPlayer(manager, opponent, data)
+		return nil
+	}
+
+	if !isFreeForGame(manager, opponent) {
+		delete(manager.Rematches, rematch.GameID)
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - Opponent is in another game;>", message.Rid, actionRematch))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Rematch cancelled - You are in another game;rematch:cancelled;gameID:%d;>", actionRematch, rematch.GameID))
+		SendToPlayer(manager, opponent, data)
+		return errors.New("rematch: opponent is in another game")
+	}
+
+	// Both players agreed - start new game
+	game, errStart := StartRematch(manager, rematch)
+
+	if errStart != nil {
+		delete(manager.Rematches, rematch.GameID)
+
+		data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Rematch failed - %s;>", message.Rid, actionRematch, errStart.Error()))
+		_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+		data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Rematch cancelled - %s;rematch:cancelled;gameID:%d;>", actionRematch, errStart.Error(), rematch.GameID))
+		SendToPlayer(manager, opponent, data)
+		return fmt.Errorf("rematch: %s", errStart.Error())
+	}
+
+	rules := BuildRulesContent(game.Rules)
+
+	data := []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Rematch started;rematch:started;previousGameID:%d;GameID:%d;player:%d;%s>", message.Rid, actionRematch, rematch.GameID, game.UID, PlayerSide(game, player), rules))
+	_ = communication.SendID(manager.CommunicationServer, data, message.Source)
+
+	data = []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Rematch started;rematch:started;previousGameID:%d;GameID:%d;player:%d;%s>", actionRematch, rematch.GameID, game.UID, PlayerSide(game, opponent), rules))
+	SendToPlayer(manager, opponent, data)
+
+	return nil
+}
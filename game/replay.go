@@ -0,0 +1,430 @@
+package game
+
+import (
+	"../communication"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How often (in ticks) state checksum is stored in replay
+const replayChecksumInterval = 30
+
+// Kinds of replay events
+const (
+	replayHeader   = "header"
+	replayJoin     = "join"
+	replayLeave    = "leave"
+	replayState    = "state"
+	replayInput    = "input"
+	replayChecksum = "checksum"
+	replayEnd      = "end"
+)
+
+// Single line of replay file
+type ReplayEvent struct {
+	Kind string `json:"kind"`
+	Tick int64  `json:"tick"`
+	// Unix time in milliseconds when event happened
+	Time int64 `json:"time"`
+
+	// Header - initial parameters of game
+	Game        int    `json:"game,omitempty"`
+	Seed        int64  `json:"seed,omitempty"`
+	Rules       *Rules `json:"rules,omitempty"`
+	PlayerSpeed int    `json:"playerSpeed,omitempty"`
+	PlayerGap   int    `json:"playerGap,omitempty"`
+
+	// Join and leave - side of field (1 top, 2 bottom) and player ID, input - ID of player who sent it
+	Side   int `json:"side,omitempty"`
+	Player int `json:"player,omitempty"`
+	// Difficulty of joined bot
+	Bot string `json:"bot,omitempty"`
+
+	// State - lifecycle state of game
+	State string `json:"state,omitempty"`
+
+	// Input - players message
+	Type    int               `json:"type,omitempty"`
+	Content map[string]string `json:"content,omitempty"`
+
+	// Checksum of game state after tick
+	Checksum uint64 `json:"checksum,omitempty"`
+
+	// End - final score
+	Score1 int `json:"score1,omitempty"`
+	Score2 int `json:"score2,omitempty"`
+}
+
+// Writes replay of single game
+type Recorder struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	// Last recorded state of game between ticks
+	player1       int
+	player2       int
+	state         GameState
+	stateRecorded bool
+}
+
+// Starts recording of game to new file in given directory
+func StartRecording(game *GameServer, directory string) error {
+	if game == nil {
+		return errors.New("unable to start recording: game cannot be null")
+	}
+
+	path := filepath.Join(directory, fmt.Sprintf("game-%d-%s.replay", game.UID, time.Now().Format("20060102-150405")))
+	file, errCreate := os.Create(path)
+
+	if errCreate != nil {
+		return fmt.Errorf("unable to start recording: %s", errCreate.Error())
+	}
+
+	writer := bufio.NewWriter(file)
+	recorder := &Recorder{
+		file:    file,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}
+
+	game.recorder = recorder
+
+	rules := game.Rules
+	recordEvent(game, ReplayEvent{
+		Kind:        replayHeader,
+		Game:        game.UID,
+		Seed:        game.Seed,
+		Rules:       &rules,
+		PlayerSpeed: game.PLAYER_SPEED,
+		PlayerGap:   game.PLAYER_GAP,
+	})
+
+	fmt.Printf("game #%d: recording to %s\n", game.UID, path)
+
+	return nil
+}
+
+// Finishes replay file of game
+func StopRecording(game *GameServer) {
+	if game == nil || game.recorder == nil {
+		return
+	}
+
+	recordEvent(game, ReplayEvent{Kind: replayEnd, Score1: game.Score1, Score2: game.Score2})
+
+	_ = game.recorder.writer.Flush()
+	_ = game.recorder.file.Close()
+	game.recorder = nil
+}
+
+// Writes event to replay of game
+func recordEvent(game *GameServer, event ReplayEvent) {
+	if game.recorder == nil {
+		return
+	}
+
+	event.Tick = game.Tick
+	event.Time = time.Now().UnixNano() / int64(time.Millisecond)
+
+	_ = game.recorder.encoder.Encode(event)
+}
+
+// Returns ID of player or 0 when there is no player
+func recordedPlayerID(player *Player) int {
+	if player == nil {
+		return 0
+	}
+
+	return player.ID
+}
+
+// Returns difficulty of bot or empty string for human player
+func recordedBotDifficulty(player *Player) string {
+	if player == nil {
+		return ""
+	}
+
+	bot, isBot := player.controller.(*BotController)
+
+	if !isBot {
+		return ""
+	}
+
+	return bot.difficulty.Name
+}
+
+// Records players joining or leaving and state changes before tick is simulated
+func RecordTickStart(game *GameServer) {
+	if game.recorder == nil {
+		return
+	}
+
+	recorder := game.recorder
+	sides := []struct {
+		side     int
+		current  int
+		recorded *int
+		player   *Player
+	}{
+		{1, recordedPlayerID(game.Player1), &recorder.player1, game.Player1},
+		{2, recordedPlayerID(game.Player2), &recorder.player2, game.Player2},
+	}
+
+	for _, side := range sides {
+		if side.current == *side.recorded {
+			continue
+		}
+
+		if *side.recorded != 0 {
+			recordEvent(game, ReplayEvent{Kind: replayLeave, Side: side.side, Player: *side.recorded})
+		}
+
+		if side.current != 0 {
+			recordEvent(game, ReplayEvent{Kind: replayJoin, Side: side.side, Player: side.current, Bot: recordedBotDifficulty(side.player)})
+		}
+
+		*side.recorded = side.current
+	}
+
+	// State changes made by tick itself are simulated again in replay
+	if !recorder.stateRecorded || game.State != recorder.state {
+		recordEvent(game, ReplayEvent{Kind: replayState, State: game.State.String()})
+		recorder.state = game.State
+		recorder.stateRecorded = true
+	}
+}
+
+// Records players message processed during tick
+func RecordInput(game *GameServer, message *GameMessage) {
+	if game.recorder == nil || message == nil {
+		return
+	}
+
+	recordEvent(game, ReplayEvent{Kind: replayInput, Player: recordedPlayerID(message.Player), Type: message.Message.Msg, Content: message.Message.Content})
+}
+
+// Records checksum of game state after tick was simulated
+func RecordTickEnd(game *GameServer) {
+	if game.recorder == nil {
+		return
+	}
+
+	game.recorder.state = game.State
+
+	if game.Tick%replayChecksumInterval != 0 {
+		return
+	}
+
+	recordEvent(game, ReplayEvent{Kind: replayChecksum, Checksum: GameChecksum(game)})
+
+	// Keep file usable even if server stops unexpectedly
+	_ = game.recorder.writer.Flush()
+}
+
+// Returns checksum of simulated game state
+func GameChecksum(game *GameServer) uint64 {
+	hash := fnv.New64a()
+	buffer := make([]byte, 8)
+
+	write := func(value float64) {
+		binary.LittleEndian.PutUint64(buffer, math.Float64bits(value))
+		_, _ = hash.Write(buffer)
+	}
+
+	write(float64(game.Tick))
+	write(float64(game.Score1))
+	write(float64(game.Score2))
+
+	if game.Ball != nil {
+		write(game.Ball.X)
+		write(game.Ball.Y)
+		write(game.Ball.Direction.X)
+		write(game.Ball.Direction.Y)
+		write(game.Ball.Speed)
+	}
+
+	for _, player := range []*Player{game.Player1, game.Player2} {
+		if player != nil {
+			write(player.x)
+			write(player.y)
+		}
+	}
+
+	return hash.Sum64()
+}
+
+// Returns player of replayed game with given ID, nil if player is not in game
+func replayedPlayer(game *GameServer, playerID int) *Player {
+	if game.Player1 != nil && game.Player1.ID == playerID {
+		return game.Player1
+	}
+
+	if game.Player2 != nil && game.Player2.ID == playerID {
+		return game.Player2
+	}
+
+	return nil
+}
+
+// Re-simulates recorded game, verifies checksums and writes state messages to output
+func Replay(input io.Reader, output io.Writer) error {
+	// Load events grouped by tick
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var header *ReplayEvent = nil
+	events := make(map[int64][]ReplayEvent)
+	var lastTick int64 = 0
+
+	for scanner.Scan() {
+		var event ReplayEvent
+
+		errDecode := json.Unmarshal(scanner.Bytes(), &event)
+
+		if errDecode != nil {
+			return fmt.Errorf("replay: invalid event: %s", errDecode.Error())
+		}
+
+		if event.Kind == replayHeader {
+			header = &event
+			continue
+		}
+
+		events[event.Tick] = append(events[event.Tick], event)
+
+		if event.Tick > lastTick {
+			lastTick = event.Tick
+		}
+	}
+
+	if errScan := scanner.Err(); errScan != nil {
+		return fmt.Errorf("replay: could not read file: %s", errScan.Error())
+	}
+
+	if header == nil || header.Rules == nil {
+		return errors.New("replay: header is missing")
+	}
+
+	// Recreate game with recorded parameters
+	manager := &Manager{}
+	game := &GameServer{
+		UID:          header.Game,
+		PLAYER_SPEED: header.PlayerSpeed,
+		PLAYER_GAP:   header.PlayerGap,
+		Messages:     make([]*GameMessage, 0, 10),
+		Seed:         header.Seed,
+	}
+
+	ApplyRules(game, *header.Rules)
+
+	InitializeBall(game)
+
+	verified := 0
+	writer := bufio.NewWriter(output)
+	var end *ReplayEvent = nil
+
+	for game.Tick = 0; game.Tick <= lastTick; game.Tick++ {
+
+		// Apply recorded events of tick
+		for _, event := range events[game.Tick] {
+			switch event.Kind {
+			case replayJoin:
+				player := &Player{ID: event.Player, userName: fmt.Sprintf("player%d", event.Side)}
+				if event.Bot != "" {
+					difficulty, errDifficulty := ParseBotDifficulty(event.Bot)
+					if errDifficulty != nil {
+						return fmt.Errorf("replay: %s", errDifficulty.Error())
+					}
+					player = CreateBot(manager, difficulty)
+					player.ID = event.Player
+				}
+				if event.Side == 1 {
+					game.Player1 = player
+				} else {
+					game.Player2 = player
+				}
+				_ = PlacePlayer(game, player)
+			case replayLeave:
+				if event.Side == 1 {
+					game.Player1 = nil
+				} else {
+					game.Player2 = nil
+				}
+			case replayState:
+				state, errState := ParseGameState(event.State)
+				if errState != nil {
+					return fmt.Errorf("replay: %s", errState.Error())
+				}
+				EnterGameState(game, state)
+			case replayInput:
+				message := &communication.Message{Msg: event.Type, Content: event.Content}
+				game.Messages = append(game.Messages, &GameMessage{Player: replayedPlayer(game, event.Player), Message: message})
+			case replayEnd:
+				endEvent := event
+				end = &endEvent
+			}
+		}
+
+		// Game ended after previous tick
+		if end != nil {
+			break
+		}
+
+		GameTick(manager, game)
+
+		// Export state
+		stateMessage, errState := BuildGameStateMessage(game)
+
+		if errState == nil {
+			_, _ = writer.WriteString(stateMessage + "\n")
+		}
+
+		// Verify checksums
+		for _, event := range events[game.Tick] {
+			if event.Kind != replayChecksum {
+				continue
+			}
+
+			checksum := GameChecksum(game)
+
+			if checksum != event.Checksum {
+				_ = writer.Flush()
+				return fmt.Errorf("replay: checksum mismatch at tick %d (recorded %d, simulated %d)", game.Tick, event.Checksum, checksum)
+			}
+
+			verified++
+		}
+	}
+
+	_ = writer.Flush()
+
+	if end != nil && (game.Score1 != end.Score1 || game.Score2 != end.Score2) {
+		return fmt.Errorf("replay: score mismatch (recorded %d:%d, simulated %d:%d)", end.Score1, end.Score2, game.Score1, game.Score2)
+	}
+
+	fmt.Printf("Replay of game #%d (seed %d) verified: %d ticks, %d checksums, score %d:%d\n", game.UID, game.Seed, game.Tick, verified, game.Score1, game.Score2)
+
+	return nil
+}
+
+// Replays recorded game from file
+func ReplayFile(path string, output io.Writer) error {
+	file, errOpen := os.Open(path)
+
+	if errOpen != nil {
+		return fmt.Errorf("replay: could not open file: %s", errOpen.Error())
+	}
+
+	defer file.Close()
+
+	return Replay(file, output)
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reasons of game end sent in game end message
+const (
+	// Winner reached score limit
+	endReasonScore = "score"
+	// Opponent did not reconnect in time
+	endReasonForfeit = "forfeit"
+)
+
+// Result of finished game
+type GameResult struct {
+	GameID   int
+	Player1  string
+	Player2  string
+	Score1   int
+	Score2   int
+	Winner   int
+	Reason   string
+	Finished time.Time
+}
+
+// Returns name of player or empty string when there is no player
+func resultPlayerName(player *Player) string {
+	if player == nil {
+		return ""
+	}
+
+	return player.userName
+}
+
+// Finishes game with winner, players are informed and result is stored once game loop ends
+func EndGame(manager *Manager, game *GameServer, winner int, reason string) error {
+	// Game can end only once
+	if game.State == StateFinished {
+		return errors.New("game already finished")
+	}
+
+	errState := SetGameState(manager, game, StateFinished, fmt.Sprintf("player%d won by %s", winner, reason))
+
+	if errState != nil {
+		return errState
+	}
+
+	game.winner = winner
+	game.endReason = reason
+
+	return nil
+}
+
+// Logs result of finished game and updates ratings of players, runs on manager goroutine
+func RecordResult(manager *Manager, game *GameServer, winner int, reason string) {
+	result := GameResult{
+		GameID:   game.UID,
+		Player1:  resultPlayerName(game.Player1),
+		Player2:  resultPlayerName(game.Player2),
+		Score1:   game.Score1,
+		Score2:   game.Score2,
+		Winner:   winner,
+		Reason:   reason,
+		Finished: time.Now(),
+	}
+
+	UpdateRatings(game, winner)
+	SaveAccountRating(manager, game.Player1)
+	SaveAccountRating(manager, game.Player2)
+
+	fmt.Printf("game #%d result: %s %d:%d %s, player%d won (%s)\n", result.GameID, result.Player1, result.Score1, result.Score2, result.Player2, result.Winner, result.Reason)
+}
@@ -0,0 +1,379 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Rules of single game, chosen when game is created
+type Rules struct {
+	// Name of preset rules were based on ("custom" when changed)
+	Preset string `json:"preset"`
+	// Score needed to win
+	ScoreLimit int `json:"scoreLimit"`
+	// Winner needs to lead by two points
+	WinByTwo bool `json:"winByTwo"`
+	// Size of game field
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Ticks per second
+	Tps int `json:"tps"`
+	// Size of paddle
+	PaddleWidth  int `json:"paddleWidth"`
+	PaddleHeight int `json:"paddleHeight"`
+	// Ball speed curve - speed after serve, maximum speed and speed added by each paddle hit (pixels per tick)
+	BallSpeed        float64 `json:"ballSpeed"`
+	BallMaxSpeed     float64 `json:"ballMaxSpeed"`
+	BallAcceleration float64 `json:"ballAcceleration"`
+	// Ball angle from vertical after paddle edge hit in degrees
+	BounceAngle float64 `json:"bounceAngle"`
+	// Share of paddle speed passed to ball
+	SpinFactor float64 `json:"spinFactor"`
+	// Seconds between point (or kickoff) and serve
+	ServeDelay float64 `json:"serveDelay"`
+	// Who receives serve - player who conceded or players alternate
+	ServeMode string `json:"serveMode"`
+	// How many times and how long in total (seconds) can each player pause game, longest single pause (seconds)
+	PauseLimit int     `json:"pauseLimit"`
+	PauseTime  float64 `json:"pauseTime"`
+	MaxPause   float64 `json:"maxPause"`
+	// Seconds disconnected player has to come back before he forfeits
+	ReconnectGrace float64 `json:"reconnectGrace"`
+}
+
+// Name of rules which were changed by creator
+const customPreset = "custom"
+
+// Serve modes
+const (
+	// Ball is served toward player who conceded last point
+	serveToConceder = "conceder"
+	// Ball is served toward players in turns
+	serveAlternate = "alternate"
+)
+
+// Available rule presets
+var rulePresets = map[string]Rules{
+	"classic": {
+		Preset:           "classic",
+		ScoreLimit:       10,
+		WinByTwo:         false,
+		Width:            375,
+		Height:           600,
+		Tps:              30,
+		PaddleWidth:      80,
+		PaddleHeight:     3,
+		BallSpeed:        3,
+		BallMaxSpeed:     15,
+		BallAcceleration: 1,
+		BounceAngle:      defaultBounceAngle,
+		SpinFactor:       defaultSpinFactor,
+		ServeDelay:       2,
+		ServeMode:        serveToConceder,
+		PauseLimit:       3,
+		PauseTime:        60,
+		MaxPause:         30,
+		ReconnectGrace:   30,
+	},
+	"fast": {
+		Preset:           "fast",
+		ScoreLimit:       10,
+		WinByTwo:         false,
+		Width:            375,
+		Height:           600,
+		Tps:              30,
+		PaddleWidth:      70,
+		PaddleHeight:     3,
+		BallSpeed:        5,
+		BallMaxSpeed:     18,
+		BallAcceleration: 1.5,
+		BounceAngle:      defaultBounceAngle,
+		SpinFactor:       defaultSpinFactor,
+		ServeDelay:       1,
+		ServeMode:        serveToConceder,
+		PauseLimit:       2,
+		PauseTime:        30,
+		MaxPause:         20,
+		ReconnectGrace:   20,
+	},
+	"tournament": {
+		Preset:           "tournament",
+		ScoreLimit:       11,
+		WinByTwo:         true,
+		Width:            375,
+		Height:           600,
+		Tps:              30,
+		PaddleWidth:      80,
+		PaddleHeight:     3,
+		BallSpeed:        3,
+		BallMaxSpeed:     15,
+		BallAcceleration: 1,
+		BounceAngle:      defaultBounceAngle,
+		SpinFactor:       defaultSpinFactor,
+		ServeDelay:       3,
+		ServeMode:        serveAlternate,
+		PauseLimit:       2,
+		PauseTime:        120,
+		MaxPause:         60,
+		ReconnectGrace:   60,
+	},
+}
+
+// Returns rules used when creator does not choose any
+func DefaultRules() Rules {
+	return rulePresets["classic"]
+}
+
+// Returns names of available presets
+func RulePresetNames() []string {
+	names := make([]string, 0, len(rulePresets))
+
+	for name := range rulePresets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Checks rules against server side bounds
+func ValidateRules(rules Rules) error {
+	if rules.ScoreLimit < 1 || rules.ScoreLimit > 50 {
+		return errors.New("scoreLimit must be between 1 and 50")
+	}
+
+	if rules.Width < 200 || rules.Width > 1000 {
+		return errors.New("width must be between 200 and 1000")
+	}
+
+	if rules.Height < 300 || rules.Height > 1200 {
+		return errors.New("height must be between 300 and 1200")
+	}
+
+	if rules.Tps < 10 || rules.Tps > 60 {
+		return errors.New("tps must be between 10 and 60")
+	}
+
+	if rules.PaddleWidth < 20 || rules.PaddleWidth > rules.Width/2 {
+		return errors.New("paddleWidth must be between 20 and half of width")
+	}
+
+	if rules.PaddleHeight < 2 || rules.PaddleHeight > 10 {
+		return errors.New("paddleHeight must be between 2 and 10")
+	}
+
+	if rules.BallMaxSpeed < 1 || rules.BallMaxSpeed > 30 {
+		return errors.New("ballMaxSpeed must be between 1 and 30")
+	}
+
+	if rules.BallSpeed < 1 || rules.BallSpeed > rules.BallMaxSpeed {
+		return errors.New("ballSpeed must be between 1 and ballMaxSpeed")
+	}
+
+	if rules.BallAcceleration < 0 || rules.BallAcceleration > 5 {
+		return errors.New("ballAcceleration must be between 0 and 5")
+	}
+
+	if rules.BounceAngle < 0 || rules.BounceAngle > maxBounceDeflection {
+		return fmt.Errorf("bounceAngle must be between 0 and %d", maxBounceDeflection)
+	}
+
+	if rules.SpinFactor < 0 || rules.SpinFactor > 1 {
+		return errors.New("spin must be between 0 and 1")
+	}
+
+	if rules.ServeDelay < 0 || rules.ServeDelay > 10 {
+		return errors.New("serveDelay must be between 0 and 10")
+	}
+
+	if rules.ServeMode != serveToConceder && rules.ServeMode != serveAlternate {
+		return fmt.Errorf("serveMode must be %s or %s", serveToConceder, serveAlternate)
+	}
+
+	if rules.PauseLimit < 0 || rules.PauseLimit > 10 {
+		return errors.New("pauseLimit must be between 0 and 10")
+	}
+
+	if rules.PauseTime < 0 || rules.PauseTime > 600 {
+		return errors.New("pauseTime must be between 0 and 600")
+	}
+
+	if rules.MaxPause < 1 || rules.MaxPause > 600 {
+		return errors.New("maxPause must be between 1 and 600")
+	}
+
+	if rules.ReconnectGrace < 1 || rules.ReconnectGrace > 600 {
+		return errors.New("reconnectGrace must be between 1 and 600")
+	}
+
+	return nil
+}
+
+// Reads optional rules from create game message - preset and overrides of its values
+func ParseRules(content map[string]string) (Rules, error) {
+	rules := DefaultRules()
+
+	presetValue, presetPresent := content["preset"]
+
+	if presetPresent {
+		preset, presetExist := rulePresets[presetValue]
+
+		if !presetExist {
+			return Rules{}, fmt.Errorf("unknown preset %s", presetValue)
+		}
+
+		rules = preset
+	}
+
+	intValues := []struct {
+		key   string
+		value *int
+	}{
+		{"scoreLimit", &rules.ScoreLimit},
+		{"width", &rules.Width},
+		{"height", &rules.Height},
+		{"tps", &rules.Tps},
+		{"paddleWidth", &rules.PaddleWidth},
+		{"paddleHeight", &rules.PaddleHeight},
+		{"pauseLimit", &rules.PauseLimit},
+	}
+
+	for _, item := range intValues {
+		value, present := content[item.key]
+
+		if !present {
+			continue
+		}
+
+		parsed, errParse := strconv.Atoi(value)
+
+		if errParse != nil {
+			return Rules{}, fmt.Errorf("%s must be number", item.key)
+		}
+
+		*item.value = parsed
+		rules.Preset = customPreset
+	}
+
+	floatValues := []struct {
+		key   string
+		value *float64
+	}{
+		{"ballSpeed", &rules.BallSpeed},
+		{"ballMaxSpeed", &rules.BallMaxSpeed},
+		{"ballAcceleration", &rules.BallAcceleration},
+		{"bounceAngle", &rules.BounceAngle},
+		{"spin", &rules.SpinFactor},
+		{"serveDelay", &rules.ServeDelay},
+		{"pauseTime", &rules.PauseTime},
+		{"maxPause", &rules.MaxPause},
+		{"reconnectGrace", &rules.ReconnectGrace},
+	}
+
+	for _, item := range floatValues {
+		value, present := content[item.key]
+
+		if !present {
+			continue
+		}
+
+		parsed, errParse := strconv.ParseFloat(value, 64)
+
+		// NaN passes every range check, infinity overflows durations
+		if errParse != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return Rules{}, fmt.Errorf("%s must be number", item.key)
+		}
+
+		*item.value = parsed
+		rules.Preset = customPreset
+	}
+
+	winByTwoValue, winByTwoPresent := content["winByTwo"]
+
+	if winByTwoPresent {
+		parsed, errParse := strconv.ParseBool(winByTwoValue)
+
+		if errParse != nil {
+			return Rules{}, errors.New("winByTwo must be true or false")
+		}
+
+		rules.WinByTwo = parsed
+		rules.Preset = customPreset
+	}
+
+	serveModeValue, serveModePresent := content["serveMode"]
+
+	if serveModePresent {
+		rules.ServeMode = serveModeValue
+		rules.Preset = customPreset
+	}
+
+	errValidate := ValidateRules(rules)
+
+	if errValidate != nil {
+		return Rules{}, errValidate
+	}
+
+	return rules, nil
+}
+
+// Applies rules to game
+func ApplyRules(game *GameServer, rules Rules) {
+	game.Rules = rules
+	game.Tps = rules.Tps
+	game.WIDTH = rules.Width
+	game.HEIGHT = rules.Height
+	game.PLAYER_SIZE_WIDTH = rules.PaddleWidth
+	game.PLAYER_SIZE_HEIGHT = rules.PaddleHeight
+	game.BOUNCE_ANGLE = rules.BounceAngle
+	game.SPIN_FACTOR = rules.SpinFactor
+}
+
+// Builds message content describing rules
+func BuildRulesContent(rules Rules) string {
+	msg := fmt.Sprintf("preset:%s;", rules.Preset)
+	msg += fmt.Sprintf("scoreLimit:%d;", rules.ScoreLimit)
+	msg += fmt.Sprintf("winByTwo:%t;", rules.WinByTwo)
+	msg += fmt.Sprintf("width:%d;", rules.Width)
+	msg += fmt.Sprintf("height:%d;", rules.Height)
+	msg += fmt.Sprintf("tps:%d;", rules.Tps)
+	msg += fmt.Sprintf("paddleWidth:%d;", rules.PaddleWidth)
+	msg += fmt.Sprintf("paddleHeight:%d;", rules.PaddleHeight)
+	msg += fmt.Sprintf("ballSpeed:%g;", rules.BallSpeed)
+	msg += fmt.Sprintf("ballMaxSpeed:%g;", rules.BallMaxSpeed)
+	msg += fmt.Sprintf("ballAcceleration:%g;", rules.BallAcceleration)
+	msg += fmt.Sprintf("bounceAngle:%g;", rules.BounceAngle)
+	msg += fmt.Sprintf("spin:%g;", rules.SpinFactor)
+	msg += fmt.Sprintf("serveDelay:%g;", rules.ServeDelay)
+	msg += fmt.Sprintf("serveMode:%s;", rules.ServeMode)
+	msg += fmt.Sprintf("pauseLimit:%d;", rules.PauseLimit)
+	msg += fmt.Sprintf("pauseTime:%g;", rules.PauseTime)
+	msg += fmt.Sprintf("maxPause:%g;", rules.MaxPause)
+	msg += fmt.Sprintf("reconnectGrace:%g;", rules.ReconnectGrace)
+
+	return msg
+}
+
+// Returns winning side of game (1 or 2), 0 if game is not decided yet
+func GameWinner(game *GameServer) int {
+	limit := game.Rules.ScoreLimit
+	lead := 1
+
+	if game.Rules.WinByTwo {
+		lead = 2
+	}
+
+	if game.Score1 >= limit && game.Score1-game.Score2 >= lead {
+		return 1
+	}
+
+	if game.Score2 >= limit && game.Score2-game.Score1 >= lead {
+		return 2
+	}
+
+	return 0
+}
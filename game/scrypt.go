@@ -0,0 +1,209 @@
+package game
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CPU and memory cost of password hash, memory used is 128 * scryptN * scryptR bytes
+	scryptN = 1 << 14
+	// Block size of password hash
+	scryptR = 8
+	// Parallelization of password hash
+	scryptP = 1
+	// Length of random salt
+	saltLength = 16
+	// Length of password hash
+	hashLength = 32
+	// Name of hash in encoded password hash
+	scryptName = "scrypt"
+)
+
+// Salsa20/8 core applied to block in place
+func salsa208(block *[16]uint32) {
+	x := *block
+
+	for i := 0; i < 8; i += 2 {
+		// Columns
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		// Rows
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	for i := range block {
+		block[i] += x[i]
+	}
+}
+
+// Mixes 2*r blocks of 16 words from input to output
+func blockMix(input []uint32, output []uint32, r int) {
+	var x [16]uint32
+	copy(x[:], input[(2*r-1)*16:])
+
+	for i := 0; i < 2*r; i++ {
+		for j := range x {
+			x[j] ^= input[i*16+j]
+		}
+
+		salsa208(&x)
+
+		// Even blocks go to first half of output, odd blocks to second half
+		offset := (i/2)*16 + (i%2)*r*16
+		copy(output[offset:offset+16], x[:])
+	}
+}
+
+// Sequential memory-hard mixing of single block
+func roMix(block []byte, n int, r int) {
+	words := 32 * r
+	x := make([]uint32, words)
+	y := make([]uint32, words)
+	v := make([]uint32, words*n)
+
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(block[i*4:])
+	}
+
+	for i := 0; i < n; i++ {
+		copy(v[i*words:], x)
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+
+	for i := 0; i < n; i++ {
+		j := int(x[(2*r-1)*16] & uint32(n-1))
+
+		for k := range x {
+			x[k] ^= v[j*words+k]
+		}
+
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+
+	for i := range x {
+		binary.LittleEndian.PutUint32(block[i*4:], x[i])
+	}
+}
+
+// Derives key from password by scrypt (RFC 7914)
+func scryptKey(password []byte, salt []byte, n int, r int, p int, keyLength int) ([]byte, error) {
+	if n < 2 || n&(n-1) != 0 {
+		return nil, errors.New("scrypt: N must be power of 2")
+	}
+
+	if r < 1 || p < 1 || r*p >= 1<<30 {
+		return nil, errors.New("scrypt: invalid parameters")
+	}
+
+	blocks, errKey := pbkdf2.Key(sha256.New, string(password), salt, 1, p*128*r)
+
+	if errKey != nil {
+		return nil, errKey
+	}
+
+	for i := 0; i < p; i++ {
+		roMix(blocks[i*128*r:(i+1)*128*r], n, r)
+	}
+
+	return pbkdf2.Key(sha256.New, string(password), blocks, 1, keyLength)
+}
+
+// Returns salted hash of password encoded with its parameters
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+
+	_, errRandom := rand.Read(salt)
+
+	if errRandom != nil {
+		return "", errors.New("cannot generate salt")
+	}
+
+	hash, errHash := scryptKey([]byte(password), salt, scryptN, scryptR, scryptP, hashLength)
+
+	if errHash != nil {
+		return "", errHash
+	}
+
+	encoding := base64.RawStdEncoding
+
+	return fmt.Sprintf("%s$%d$%d$%d$%s$%s", scryptName, scryptN, scryptR, scryptP, encoding.EncodeToString(salt), encoding.EncodeToString(hash)), nil
+}
+
+// Checks password against encoded hash
+func VerifyPassword(password string, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 6 || parts[0] != scryptName {
+		return false, errors.New("unknown password hash format")
+	}
+
+	parameters := make([]int, 3)
+
+	for i := range parameters {
+		value, errParse := strconv.Atoi(parts[i+1])
+
+		if errParse != nil {
+			return false, errors.New("invalid password hash parameters")
+		}
+
+		parameters[i] = value
+	}
+
+	encoding := base64.RawStdEncoding
+	salt, errSalt := encoding.DecodeString(parts[4])
+	expected, errExpected := encoding.DecodeString(parts[5])
+
+	if errSalt != nil || errExpected != nil {
+		return false, errors.New("invalid password hash encoding")
+	}
+
+	hash, errHash := scryptKey([]byte(password), salt, parameters[0], parameters[1], parameters[2], len(expected))
+
+	if errHash != nil {
+		return false, errHash
+	}
+
+	return subtle.ConstantTimeCompare(hash, expected) == 1, nil
+}
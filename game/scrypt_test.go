@@ -0,0 +1,63 @@
+package game
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 7914 section 12
+func TestScryptKey(t *testing.T) {
+	cases := []struct {
+		password string
+		salt     string
+		n        int
+		r        int
+		p        int
+		expected string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+
+	for _, c := range cases {
+		key, errKey := scryptKey([]byte(c.password), []byte(c.salt), c.n, c.r, c.p, 64)
+
+		if errKey != nil {
+			t.Fatalf("scrypt(%q, %q, %d, %d, %d): %s", c.password, c.salt, c.n, c.r, c.p, errKey.Error())
+		}
+
+		if hex.EncodeToString(key) != c.expected {
+			t.Errorf("scrypt(%q, %q, %d, %d, %d) = %x, expected %s", c.password, c.salt, c.n, c.r, c.p, key, c.expected)
+		}
+	}
+}
+
+// Hashed password is accepted and other password is not
+func TestVerifyPassword(t *testing.T) {
+	encoded, errHash := HashPassword("longpassword")
+
+	if errHash != nil {
+		t.Fatalf("hash: %s", errHash.Error())
+	}
+
+	cases := []struct {
+		password string
+		valid    bool
+	}{
+		{"longpassword", true},
+		{"longpassword2", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		valid, errVerify := VerifyPassword(c.password, encoded)
+
+		if errVerify != nil {
+			t.Fatalf("verify %q: %s", c.password, errVerify.Error())
+		}
+
+		if valid != c.valid {
+			t.Errorf("verify %q = %t, expected %t", c.password, valid, c.valid)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// Break between games of series
+	seriesBreak = 10 * time.Second
+	// Longest allowed series
+	maxBestOf = 9
+)
+
+// Consecutive games between same two players, first to win majority of games wins series
+type Series struct {
+	// Series ID
+	ID int
+	// Number of games series can have at most
+	BestOf int
+	// Rules of every game in series
+	Rules Rules
+	// Players of series, known after first game ends
+	Player1 *Player
+	Player2 *Player
+	// Won games by player ID
+	wins map[int]int
+	// IDs of games played in series
+	Games []int
+	// Player ID of series winner, 0 while undecided
+	Winner int
+	// Series ended without winner
+	Cancelled bool
+}
+
+// Reads optional number of games in series from create game message, 1 means single game
+func ParseBestOf(content map[string]string) (int, error) {
+	bestOfValue, bestOfPresent := content["bestOf"]
+
+	if !bestOfPresent {
+		return 1, nil
+	}
+
+	bestOf, errParse := strconv.Atoi(bestOfValue)
+
+	if errParse != nil {
+		return 0, errors.New("bestOf must be number")
+	}
+
+	if bestOf < 1 || bestOf > maxBestOf || bestOf%2 == 0 {
+		return 0, fmt.Errorf("bestOf must be odd number between 1 and %d", maxBestOf)
+	}
+
+	return bestOf, nil
+}
+
+// Creates series and makes game its first game
+func CreateSeries(manager *Manager, game *GameServer, bestOf int) *Series {
+	series := &Series{
+		ID:     manager.nextSeriesID,
+		BestOf: bestOf,
+		Rules:  game.Rules,
+		wins:   make(map[int]int),
+		Games:  []int{game.UID},
+	}
+
+	manager.nextSeriesID++
+	game.Series = series
+
+	return series
+}
+
+// Returns number of games player won in series
+func SeriesWins(series *Series, player *Player) int {
+	if player == nil {
+		return 0
+	}
+
+	return series.wins[player.ID]
+}
+
+// Returns if series has ended
+func IsSeriesOver(series *Series) bool {
+	return series.Winner != 0 || series.Cancelled
+}
+
+// Counts finished game of series
+func RecordSeriesGame(series *Series, game *GameServer, winner int) {
+	if series.Player1 == nil || series.Player2 == nil {
+		series.Player1 = game.Player1
+		series.Player2 = game.Player2
+	}
+
+	winningPlayer := game.Player1
+	if winner == 2 {
+		winningPlayer = game.Player2
+	}
+
+	if winningPlayer == nil {
+		return
+	}
+
+	series.wins[winningPlayer.ID]++
+
+	if series.wins[winningPlayer.ID] > series.BestOf/2 {
+		series.Winner = winningPlayer.ID
+		fmt.Printf("series #%d won by %s\n", series.ID, winningPlayer.userName)
+	}
+}
+
+// Builds message content describing series progress from view of given game sides
+func BuildSeriesContent(game *GameServer) string {
+	series := game.Series
+
+	if series == nil {
+		return ""
+	}
+
+	msg := fmt.Sprintf("series:%d;", series.ID)
+	msg += fmt.Sprintf("bestOf:%d;", series.BestOf)
+	msg += fmt.Sprintf("seriesGame:%d;", len(series.Games))
+	msg += fmt.Sprintf("seriesScore1:%d;", SeriesWins(series, game.Player1))
+	msg += fmt.Sprintf("seriesScore2:%d;", SeriesWins(series, game.Player2))
+
+	return msg
+}
+
+// Series game waiting for break to pass
+type PendingSeriesGame struct {
+	// Game of series which just ended
+	Finished *GameServer
+	// Time when next game starts
+	Due time.Time
+}
+
+// Plans next game of series after break, runs on manager goroutine
+func ContinueSeries(manager *Manager, game *GameServer) {
+	series := game.Series
+
+	if series == nil || IsSeriesOver(series) {
+		return
+	}
+
+	// Game ended without winner
+	if game.State != StateFinished || series.Player1 == nil || series.Player2 == nil {
+		CancelSeries(manager, series, "game was aborted")
+		return
+	}
+
+	pending := &PendingSeriesGame{
+		Finished: game,
+		Due:      time.Now().Add(seriesBreak),
+	}
+
+	manager.pendingSeries = append(manager.pendingSeries, pending)
+}
+
+// Starts series games whose break passed
+func StartPendingSeriesGames(manager *Manager) {
+	now := time.Now()
+	waiting := manager.pendingSeries[:0]
+
+	for _, pending := range manager.pendingSeries {
+		if now.Before(pending.Due) {
+			waiting = append(waiting, pending)
+			continue
+		}
+
+		StartNextSeriesGame(manager, pending.Finished)
+	}
+
+	manager.pendingSeries = waiting
+}
+
+// Starts next game of series, sides of players are swapped
+func StartNextSeriesGame(manager *Manager, game *GameServer) {
+	series := game.Series
+
+	// Players have to be still here and free
+	player1 := game.Player2
+	player2 := game.Player1
+
+	for _, player := range []*Player{player1, player2} {
+		if player == nil || !IsAlive(player) || !isFreeForGame(manager, player) {
+			CancelSeries(manager, series, "player is not available")
+			return
+		}
+	}
+
+	nextGame, errCreate := CreateConfiguredGame(manager, player1, series.Rules, time.Now().UnixNano())
+
+	if errCreate != nil {
+		CancelSeries(manager, series, errCreate.Error())
+		return
+	}
+
+	nextGame.Player1 = player1
+	nextGame.Player2 = player2
+	nextGame.Series = series
+	nextGame.Privacy = game.Privacy
+	series.Games = append(series.Games, nextGame.UID)
+
+	fmt.Printf("series #%d: game %d of %d started as game #%d\n", series.ID, len(series.Games), series.BestOf, nextGame.UID)
+
+	// Inform players about new game
+	rules := BuildRulesContent(nextGame.Rules)
+
+	for side, player := range []*Player{player1, player2} {
+		data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:ok;msg:Next game of series started;GameID:%d;player:%d;%s%s>", actionSeriesGame, nextGame.UID, side+1, BuildSeriesContent(nextGame), rules))
+		SendToPlayer(manager, player, data)
+	}
+
+	go GameStart(manager, nextGame)
+}
+
+// Ends series without winner
+func CancelSeries(manager *Manager, series *Series, reason string) {
+	series.Cancelled = true
+
+	fmt.Printf("series #%d cancelled: %s\n", series.ID, reason)
+
+	for _, player := range []*Player{series.Player1, series.Player2} {
+		data := []byte(fmt.Sprintf("<id:0;rid:0;type:%d;|status:error;msg:Series cancelled - %s;series:%d;>", actionSeriesGame, reason, series.ID))
+		SendToPlayer(manager, player, data)
+	}
+}
@@ -0,0 +1,77 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Random bytes of session token
+	sessionTokenBytes = 32
+	// Session expires when player does not communicate this long
+	defaultSessionIdle = 30 * time.Minute
+)
+
+// Returns idle period of sessions from PONG_SESSION_IDLE (seconds) or default period
+func SessionIdleFromEnv() (time.Duration, error) {
+	value := os.Getenv("PONG_SESSION_IDLE")
+
+	if value == "" {
+		return defaultSessionIdle, nil
+	}
+
+	seconds, errParse := strconv.ParseFloat(value, 64)
+
+	if errParse != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds <= 0 {
+		return 0, errors.New("PONG_SESSION_IDLE must be positive number of seconds")
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Issues new session token to player, previous token stops working
+func IssueSessionToken(player *Player) (string, error) {
+	buffer := make([]byte, sessionTokenBytes)
+
+	_, errRandom := rand.Read(buffer)
+
+	if errRandom != nil {
+		return "", errors.New("cannot generate session token")
+	}
+
+	// URL alphabet has no control bytes of codec
+	player.sessionToken = base64.RawURLEncoding.EncodeToString(buffer)
+
+	return player.sessionToken, nil
+}
+
+// Returns if session of player expired
+func isSessionExpired(manager *Manager, player *Player) bool {
+	idle := time.Since(time.Unix(player.lastCommunication, 0))
+
+	return idle > manager.SessionIdle
+}
+
+// Checks session token of player, expired session is ended
+func CheckSessionToken(manager *Manager, player *Player, token string) error {
+	if player.sessionToken == "" {
+		return errors.New("session expired")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(player.sessionToken)) != 1 {
+		return errors.New("invalid session token")
+	}
+
+	if isSessionExpired(manager, player) {
+		player.sessionToken = ""
+		return errors.New("session expired")
+	}
+
+	return nil
+}
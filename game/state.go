@@ -0,0 +1,300 @@
+package game
+
+import (
+	"../communication"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Lifecycle state of game
+type GameState int
+
+const (
+	// Game waits for second player
+	StateWaitingForOpponent GameState = iota
+	// Both players are present, waiting until they are ready
+	StateReadyCheck
+	// Players are ready, ball is served after countdown
+	StateCountdown
+	// Ball is in play
+	StatePlaying
+	// Player lost connection
+	StatePausedDisconnect
+	// Player asked for pause
+	StatePausedRequested
+	// Game ended with winner
+	StateFinished
+	// Game ended without winner
+	StateAborted
+)
+
+// How long server waits for ready messages, players who did not answer are then considered ready
+const readyCheckTimeout = 10 * time.Second
+
+// Names of states used in messages
+var gameStateNames = map[GameState]string{
+	StateWaitingForOpponent: "waiting-for-opponent",
+	StateReadyCheck:         "ready-check",
+	StateCountdown:          "countdown",
+	StatePlaying:            "playing",
+	StatePausedDisconnect:   "paused-disconnect",
+	StatePausedRequested:    "paused-requested",
+	StateFinished:           "finished",
+	StateAborted:            "aborted",
+}
+
+// States game can move to from given state
+var gameStateTransitions = map[GameState][]GameState{
+	StateWaitingForOpponent: {StateReadyCheck, StateAborted},
+	StateReadyCheck:         {StateCountdown, StateWaitingForOpponent, StateAborted},
+	StateCountdown:          {StatePlaying, StatePausedDisconnect, StatePausedRequested, StateWaitingForOpponent, StateAborted},
+	StatePlaying:            {StateCountdown, StatePausedDisconnect, StatePausedRequested, StateWaitingForOpponent, StateFinished, StateAborted},
+	StatePausedDisconnect:   {StateCountdown, StateWaitingForOpponent, StateFinished, StateAborted},
+	StatePausedRequested:    {StateCountdown, StatePausedDisconnect, StateWaitingForOpponent, StateFinished, StateAborted},
+	StateFinished:           {},
+	StateAborted:            {},
+}
+
+func (state GameState) String() string {
+	name, exist := gameStateNames[state]
+
+	if !exist {
+		return "unknown"
+	}
+
+	return name
+}
+
+// Returns state with given name
+func ParseGameState(name string) (GameState, error) {
+	for state, stateName := range gameStateNames {
+		if stateName == name {
+			return state, nil
+		}
+	}
+
+	return StateAborted, fmt.Errorf("unknown game state %s", name)
+}
+
+// Returns if game can move from one state to another
+func CanTransition(from GameState, to GameState) bool {
+	for _, allowed := range gameStateTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns if game loop should still run
+func IsGameRunning(game *GameServer) bool {
+	return game.State != StateFinished && game.State != StateAborted
+}
+
+// Returns if ball is stopped
+func IsGamePaused(game *GameServer) bool {
+	return game.State != StatePlaying
+}
+
+// Moves game to new state and informs players and spectators about it
+func SetGameState(manager *Manager, game *GameServer, state GameState, reason string) error {
+	if game == nil {
+		return errors.New("cannot change game state: game cannot be null")
+	}
+
+	if game.State == state {
+		return nil
+	}
+
+	if !CanTransition(game.State, state) {
+		return fmt.Errorf("cannot change game state from %s to %s", game.State, state)
+	}
+
+	previous := game.State
+	EnterGameState(game, state)
+
+	fmt.Printf("game #%d: %s -> %s (%s)\n", game.UID, previous, state, reason)
+
+	if manager != nil {
+		msg := fmt.Sprintf("<id:%d;rid:0;type:%d;|", game.sentMessages, actionGameStateChange)
+		msg += fmt.Sprintf("gameID:%d;state:%s;previous:%s;reason:%s;>", game.UID, state, previous, reason)
+		game.sentMessages++
+
+		SendToGame(manager, game, []byte(msg))
+	}
+
+	return nil
+}
+
+// Sets state of game and prepares it, without validation
+func EnterGameState(game *GameServer, state GameState) {
+	game.State = state
+	game.StateChanged = time.Now()
+
+	switch state {
+	case StateWaitingForOpponent:
+		game.earlyReady = [3]int{}
+	case StateReadyCheck:
+		// Ready confirmed while waiting for opponent is kept
+		for index, player := range []*Player{game.Player1, game.Player2} {
+			if player != nil {
+				player.ready = IsBot(player) || game.earlyReady[index+1] == player.ID
+			}
+		}
+	case StateCountdown:
+		game.countdown = int(math.Round(game.Rules.ServeDelay * float64(game.Tps)))
+	}
+}
+
+// Informs opponent and spectators when player loses or regains connection (called only from game loop)
+func UpdatePresence(manager *Manager, game *GameServer) {
+	for index, player := range []*Player{game.Player1, game.Player2} {
+		side := index + 1
+
+		if player == nil {
+			game.awayPlayers[side] = 0
+			continue
+		}
+
+		away := game.awayPlayers[side] == player.ID
+
+		if !IsAlive(player) && !away {
+			game.awayPlayers[side] = player.ID
+			NotifyGameMembers(manager, game, player, side, memberLeft)
+		} else if IsAlive(player) && away {
+			game.awayPlayers[side] = 0
+			NotifyGameMembers(manager, game, player, side, memberReconnected)
+		}
+	}
+}
+
+// Moves game between states depending on presence, connection and readiness of players (called only from game loop)
+func UpdateGameState(manager *Manager, game *GameServer) {
+	UpdatePresence(manager, game)
+
+	player1 := game.Player1
+	player2 := game.Player2
+
+	// Somebody left - wait for new opponent
+	if player1 == nil || player2 == nil {
+		if game.State != StateWaitingForOpponent {
+			_ = SetGameState(manager, game, StateWaitingForOpponent, "opponent left")
+		}
+		return
+	}
+
+	connected := IsAlive(player1) && IsAlive(player2)
+
+	switch game.State {
+	case StateWaitingForOpponent:
+		_ = SetGameState(manager, game, StateReadyCheck, "opponent joined")
+	case StateReadyCheck:
+		if !connected {
+			return
+		}
+
+		if player1.ready && player2.ready {
+			_ = SetGameState(manager, game, StateCountdown, "players ready")
+		} else if time.Since(game.StateChanged) > readyCheckTimeout {
+			// Silent players are ready by rule, so idle client cannot hold opponent in ready check
+			_ = SetGameState(manager, game, StateCountdown, "ready check timed out")
+		}
+	case StateCountdown, StatePlaying, StatePausedRequested:
+		if !connected {
+			_ = SetGameState(manager, game, StatePausedDisconnect, fmt.Sprintf("player disconnected, forfeit in %gs", game.Rules.ReconnectGrace))
+		}
+	case StatePausedDisconnect:
+		if connected {
+			_ = SetGameState(manager, game, StateCountdown, "player reconnected")
+			return
+		}
+
+		// Player who did not come back in time forfeits
+		grace := time.Duration(game.Rules.ReconnectGrace * float64(time.Second))
+
+		if time.Since(game.StateChanged) < grace {
+			return
+		}
+
+		if IsAlive(player1) {
+			_ = EndGame(manager, game, 1, endReasonForfeit)
+		} else if IsAlive(player2) {
+			_ = EndGame(manager, game, 2, endReasonForfeit)
+		} else {
+			_ = SetGameState(manager, game, StateAborted, "both players disconnected")
+		}
+	}
+}
+
+// Counts down one tick, serves ball when countdown is over
+func CountdownTick(manager *Manager, game *GameServer) {
+	if game.State != StateCountdown {
+		return
+	}
+
+	game.countdown--
+
+	if game.countdown <= 0 {
+		_ = SetGameState(manager, game, StatePlaying, "countdown finished")
+	}
+}
+
+// Processes players ready confirmation
+func GameReadyRequest(manager *Manager, server *GameServer, player *Player, message *communication.Message) error {
+	if manager == nil {
+		return errors.New("unable to process ready: manager cannot be null")
+	}
+
+	if server == nil {
+		return errors.New("unable to process ready: game server cannot be null")
+	}
+
+	if message == nil {
+		return errors.New("unable to process ready: message cannot be null")
+	}
+
+	// Check message type
+	if message.Msg != actionReady {
+		return errors.New("unable to process ready: wrong message type")
+	}
+
+	// Player could leave game before message was processed
+	side := PlayerSide(server, player)
+
+	if player == nil || side == 0 {
+		return errors.New("unable to process ready: player is not in this game")
+	}
+
+	var errReady error = nil
+
+	switch server.State {
+	case StateReadyCheck:
+		player.ready = true
+	case StateWaitingForOpponent:
+		// Ready is applied once ready check starts
+		server.earlyReady[side] = player.ID
+	default:
+		errReady = fmt.Errorf("game is %s", server.State)
+	}
+
+	if player.client != nil {
+		var data []byte
+
+		if errReady != nil {
+			data = []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:error;msg:Cannot set ready - %s;>", message.Rid, actionReady, errReady.Error()))
+		} else {
+			data = []byte(fmt.Sprintf("<id:%d;rid:0;type:%d;|status:ok;msg:Ready;gameID:%d;state:%s;>", message.Rid, actionReady, server.UID, server.State))
+		}
+
+		_ = communication.SendID(manager.CommunicationServer, data, player.client.UID)
+	}
+
+	if errReady != nil {
+		return fmt.Errorf("unable to process ready: %s", errReady.Error())
+	}
+
+	return nil
+}
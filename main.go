@@ -5,18 +5,46 @@ import (
 	"./game"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	// Check if we have enough arguments to start communication
-	if len(os.Args) < 3 {
-		fmt.Printf("Missing arguments - atleast 2 needed, %d given\n", len(os.Args)-1)
-		fmt.Printf("Usage: ./communication <ip> <port>")
-		os.Exit(0)
+	// Replay mode - verify recorded game and export its state messages
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replay()
+		return
+	}
+
+	// Check if service manager passed listening socket
+	listenFds, errListenFds := communication.ListenFds()
+
+	if errListenFds != nil {
+		fmt.Println(errListenFds.Error())
+		os.Exit(-1)
 	}
 
-	// Initialize communication
-	serverContext, errInit := communication.Init(os.Args[1], os.Args[2])
+	var serverContext *communication.Server
+	var errInit error
+
+	if len(listenFds) > 0 {
+		// Socket activation - adopt first passed socket
+		if len(listenFds) > 1 {
+			fmt.Printf("Socket activation: %d sockets passed, using first one\n", len(listenFds))
+		}
+
+		serverContext, errInit = communication.InitFromDescriptor(listenFds[0])
+	} else {
+		// Check if we have enough arguments to start communication
+		if len(os.Args) < 3 {
+			fmt.Printf("Missing arguments - atleast 2 needed, %d given\n", len(os.Args)-1)
+			fmt.Printf("Usage: ./communication <ip> <port>")
+			os.Exit(0)
+		}
+
+		// Initialize communication
+		serverContext, errInit = communication.Init(os.Args[1], os.Args[2])
+	}
 
 	if errInit != nil {
 		fmt.Println(errInit.Error())
@@ -31,13 +59,82 @@ func main() {
 		os.Exit(-2)
 	}
 
+	// Record games if replay directory is configured
+	serverManager.ReplayDirectory = os.Getenv("PONG_REPLAY_DIR")
+
+	// Open persistent accounts
+	accounts, errAccounts := game.NewFileAccountStorage(game.AccountsFilePath())
+
+	if errAccounts != nil {
+		fmt.Printf("Cannot open accounts: %s\n", errAccounts.Error())
+		os.Exit(-3)
+	}
+
+	serverManager.Accounts = accounts
+
+	// Idle period after which session tokens expire
+	sessionIdle, errSessionIdle := game.SessionIdleFromEnv()
+
+	if errSessionIdle != nil {
+		fmt.Println(errSessionIdle.Error())
+		os.Exit(-3)
+	}
+
+	serverManager.SessionIdle = sessionIdle
+
 	// Run goroutines
 	(*serverContext).WaitGroup.Add(1)
 	go game.ManagerStart(serverContext, serverManager)
 	(*serverContext).WaitGroup.Add(1)
 	go communication.Start(serverContext)
+	(*serverContext).WaitGroup.Add(1)
+	go communication.Watchdog(serverContext)
+
+	// Inform service manager that server is ready
+	_ = communication.Notify("READY=1")
+
+	// Inform service manager about shutdown
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		received := <-signals
+		fmt.Printf("Received %s, stopping server\n", received.String())
+		_ = communication.Notify("STOPPING=1")
+		os.Exit(0)
+	}()
 
 	// Wait for all goroutines to end
 	(*serverContext).WaitGroup.Wait()
 
 }
+
+// Replays recorded game: ./server replay <file> [output]
+func replay() {
+	if len(os.Args) < 3 {
+		fmt.Printf("Missing arguments - replay file needed\n")
+		fmt.Printf("Usage: ./communication replay <file> [output]")
+		os.Exit(0)
+	}
+
+	output := os.Stdout
+
+	if len(os.Args) > 3 {
+		file, errCreate := os.Create(os.Args[3])
+
+		if errCreate != nil {
+			fmt.Println(errCreate.Error())
+			os.Exit(-1)
+		}
+
+		defer file.Close()
+		output = file
+	}
+
+	errReplay := game.ReplayFile(os.Args[2], output)
+
+	if errReplay != nil {
+		fmt.Println(errReplay.Error())
+		os.Exit(-3)
+	}
+}